@@ -49,16 +49,8 @@ func (r *Reader) Load(dest interface{}) error {
 		return errors.New("Destination must be a pointer to a slice of structs")
 	}
 
-	if r.cols == nil {
-		if r.ColumnNamesInFirstRow {
-			cols, err := r.Reader.Read()
-			if err != nil {
-				return errors.Annotate(err, "Failed to read column names from first row")
-			}
-			r.cols = cols
-		} else {
-			return errors.New("Column names are not defined")
-		}
+	if err := r.readColumnNames(); err != nil {
+		return err
 	}
 
 	destV := reflect.ValueOf(dest).Elem()
@@ -87,6 +79,59 @@ func (r *Reader) Load(dest interface{}) error {
 	return nil
 }
 
+// LoadMaps reads CSV contents into a slice of maps that associate column names
+// with values.
+func (r *Reader) LoadMaps(dest *[]map[string]string) error {
+	for {
+		m, err := r.ReadMap()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		*dest = append(*dest, m)
+	}
+	return nil
+}
+
+// ReadMap reads a single CSV row into a map that associates column names with
+// values. It returns io.EOF when there are no more rows.
+func (r *Reader) ReadMap() (map[string]string, error) {
+	if err := r.readColumnNames(); err != nil {
+		return nil, err
+	}
+	row, err := r.Reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errors.Annotate(err, "Failed to read CSV row")
+	}
+	m := make(map[string]string, len(r.cols))
+	for i, col := range r.cols {
+		if i < len(row) {
+			m[col] = row[i]
+		}
+	}
+	return m, nil
+}
+
+func (r *Reader) readColumnNames() error {
+	if r.cols != nil {
+		return nil
+	}
+	if !r.ColumnNamesInFirstRow {
+		return errors.New("Column names are not defined")
+	}
+	cols, err := r.Reader.Read()
+	if err != nil {
+		return errors.Annotate(err, "Failed to read column names from first row")
+	}
+	r.cols = cols
+	return nil
+}
+
 func unmarshal(v string, dest reflect.Value) error {
 	switch dest.Kind() {
 	case reflect.String:
@@ -40,3 +40,24 @@ Joel,true,Pesto,32.5,21`
 		t.Errorf("Result value is different: %s", cmp.Diff(exp, out))
 	}
 }
+
+func TestLoadMaps(t *testing.T) {
+	body := `name,fav_food
+Alice,Bananas
+Frank,Burrito`
+	exp := []map[string]string{
+		{"name": "Alice", "fav_food": "Bananas"},
+		{"name": "Frank", "fav_food": "Burrito"},
+	}
+
+	r := NewReader(csv.NewReader(strings.NewReader(body)))
+	var out []map[string]string
+	err := r.LoadMaps(&out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Result value is different: %s", cmp.Diff(exp, out))
+	}
+}
@@ -0,0 +1,216 @@
+package dbc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/localhots/gobelt/log"
+)
+
+// BreakerConfig configures the circuit breaker. Only connection failures and
+// timeouts are counted as failures, query errors are not.
+type BreakerConfig struct {
+	// ConsecutiveFailures is the number of consecutive failures after which
+	// the breaker opens. Zero value disables this condition.
+	ConsecutiveFailures int
+	// ErrorRate is the ratio of failed requests in a window after which the
+	// breaker opens. Zero value disables this condition.
+	ErrorRate float64
+	// MinRequests is the minimum number of requests in a window for error
+	// rate to be considered. DefaultBreakerMinRequests is used if not set.
+	MinRequests int
+	// Window is the duration of a window in which error rate is calculated.
+	// DefaultBreakerWindow is used if not set.
+	Window time.Duration
+	// OpenTimeout is the duration for which the breaker stays open before it
+	// lets probe requests through. DefaultBreakerOpenTimeout is used if not
+	// set.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probe requests that must succeed for
+	// the breaker to close. One failed probe opens the breaker again.
+	// Defaults to 1.
+	HalfOpenRequests int
+}
+
+// Circuit breaker defaults.
+const (
+	DefaultBreakerWindow      = 10 * time.Second
+	DefaultBreakerOpenTimeout = 5 * time.Second
+	DefaultBreakerMinRequests = 10
+)
+
+// ErrCircuitOpen is returned without making a query when the circuit breaker
+// is open.
+var ErrCircuitOpen = errors.New("Circuit breaker is open")
+
+type breakerState byte
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type breaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu    sync.Mutex
+	state breakerState
+	// gen is incremented on every state change, so that results of requests
+	// that were allowed in a previous state are ignored.
+	gen         uint64
+	since       time.Time
+	requests    int
+	failures    int
+	consecutive int
+	probes      int
+	successes   int
+}
+
+func newBreaker(cfg BreakerConfig) *breaker {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBreakerWindow
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = DefaultBreakerMinRequests
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &breaker{
+		cfg:   cfg,
+		now:   time.Now,
+		since: time.Now(),
+	}
+}
+
+// WithCircuitBreaker enables the circuit breaker that makes queries fail fast
+// with ErrCircuitOpen when the database is unavailable.
+func WithCircuitBreaker(cfg BreakerConfig) Option {
+	return func(c *dbWrapper) {
+		c.breaker = newBreaker(cfg)
+	}
+}
+
+// allow tells if a request can be made. It returns a generation that must be
+// passed to done along with the request result.
+func (b *breaker) allow() (uint64, error) {
+	if b == nil {
+		return 0, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.since) < b.cfg.OpenTimeout {
+			return 0, ErrCircuitOpen
+		}
+		b.setState(breakerHalfOpen, now)
+	case breakerClosed:
+		if now.Sub(b.since) >= b.cfg.Window {
+			b.since = now
+			b.requests, b.failures = 0, 0
+		}
+	}
+	if b.state == breakerHalfOpen {
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return b.gen, nil
+}
+
+// done records a request result.
+func (b *breaker) done(ctx context.Context, gen uint64, err error) {
+	b.record(ctx, gen, err, true)
+}
+
+// fail records a failure of a request that was already recorded as successful
+// by done. Queries succeed before their rows are read, so connection failures
+// that happen while rows are read are reported separately. Other errors are
+// ignored.
+func (b *breaker) fail(ctx context.Context, gen uint64, err error) {
+	if !IsConnectionError(err) {
+		return
+	}
+	b.record(ctx, gen, err, false)
+}
+
+func (b *breaker) record(ctx context.Context, gen uint64, err error, count bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		return
+	}
+
+	failed := IsConnectionError(err)
+	switch b.state {
+	case breakerHalfOpen:
+		if failed {
+			b.trip(ctx, err)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.setState(breakerClosed, b.now())
+			log.Info(ctx, "Circuit breaker closed")
+		}
+	case breakerClosed:
+		if count {
+			b.requests++
+		}
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+		if b.cfg.ConsecutiveFailures > 0 && b.consecutive >= b.cfg.ConsecutiveFailures {
+			b.trip(ctx, err)
+			return
+		}
+		if b.cfg.ErrorRate > 0 && b.requests >= b.cfg.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.cfg.ErrorRate {
+			b.trip(ctx, err)
+		}
+	}
+}
+
+func (b *breaker) trip(ctx context.Context, err error) {
+	log.Warn(ctx, "Circuit breaker opened", log.F{
+		"state": b.state,
+		"error": err,
+	})
+	b.setState(breakerOpen, b.now())
+}
+
+func (b *breaker) setState(s breakerState, now time.Time) {
+	b.state = s
+	b.gen++
+	b.since = now
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+	b.probes, b.successes = 0, 0
+}
@@ -0,0 +1,152 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	b := newBreaker(BreakerConfig{ConsecutiveFailures: 2, OpenTimeout: time.Second})
+	b.now = func() time.Time { return now }
+
+	request := func(err error) error {
+		gen, aerr := b.allow()
+		if aerr != nil {
+			return aerr
+		}
+		b.done(ctx, gen, err)
+		return nil
+	}
+
+	request(driver.ErrBadConn)
+	request(errors.New("syntax error"))
+	request(driver.ErrBadConn)
+	if b.state != breakerClosed {
+		t.Fatal("Breaker opened without consecutive failures")
+	}
+	request(context.DeadlineExceeded)
+	if err := request(nil); err != ErrCircuitOpen {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	gen, err := b.allow()
+	if err != nil {
+		t.Fatalf("Expected probe request to be allowed, got %v", err)
+	}
+	if err := request(nil); err != ErrCircuitOpen {
+		t.Errorf("Expected concurrent probe to be rejected, got %v", err)
+	}
+	b.done(ctx, gen, driver.ErrBadConn)
+	if b.state != breakerOpen {
+		t.Fatal("Expected failed probe to open breaker")
+	}
+
+	now = now.Add(time.Second)
+	if err := request(nil); err != nil {
+		t.Fatalf("Expected probe request to be allowed, got %v", err)
+	}
+	if b.state != breakerClosed {
+		t.Error("Expected successful probe to close breaker")
+	}
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	ctx := context.Background()
+	b := newBreaker(BreakerConfig{ErrorRate: 0.5, MinRequests: 4})
+	results := []error{nil, driver.ErrBadConn, nil, driver.ErrBadConn}
+	for i, res := range results {
+		gen, err := b.allow()
+		if err != nil {
+			t.Fatalf("Request %d was not allowed: %v", i, err)
+		}
+		b.done(ctx, gen, res)
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestBreakerStaleResult(t *testing.T) {
+	ctx := context.Background()
+	b := newBreaker(BreakerConfig{ConsecutiveFailures: 1})
+	stale, _ := b.allow()
+	gen, _ := b.allow()
+	b.done(ctx, gen, driver.ErrBadConn)
+	b.setState(breakerHalfOpen, time.Now())
+	b.done(ctx, stale, nil)
+	if b.successes != 0 {
+		t.Error("Result of a request from previous state was counted")
+	}
+}
+
+func TestBreakerDefaultMinRequests(t *testing.T) {
+	ctx := context.Background()
+	b := newBreaker(BreakerConfig{ErrorRate: 0.5})
+	for i := 0; i < DefaultBreakerMinRequests-1; i++ {
+		gen, err := b.allow()
+		if err != nil {
+			t.Fatalf("Request %d was not allowed: %v", i, err)
+		}
+		b.done(ctx, gen, driver.ErrBadConn)
+	}
+	gen, err := b.allow()
+	if err != nil {
+		t.Fatalf("Expected breaker to wait for %d requests, got %v", DefaultBreakerMinRequests, err)
+	}
+	b.done(ctx, gen, driver.ErrBadConn)
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestBreakerReadFailure(t *testing.T) {
+	ctx := context.Background()
+	b := newBreaker(BreakerConfig{ConsecutiveFailures: 1})
+	gen, err := b.allow()
+	if err != nil {
+		t.Fatalf("Request was not allowed: %v", err)
+	}
+	rr, err := brokenRowsDB.QueryContext(ctx, "")
+	b.done(ctx, gen, err)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	r := &rows{rows: rr, flavor: MySQL, ctx: ctx, breaker: b, gen: gen}
+	var ids []int64
+	if err := r.Load(&ids); err != driver.ErrBadConn {
+		t.Fatalf("Expected ErrBadConn, got %v", err)
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("Expected read failure to open breaker, got %v", err)
+	}
+}
+
+// brokenRowsDB returns rows that fail with driver.ErrBadConn on read.
+var brokenRowsDB = sql.OpenDB(brokenRowsConnector{})
+
+type brokenRowsConnector struct{}
+
+func (brokenRowsConnector) Connect(context.Context) (driver.Conn, error) {
+	return brokenRowsConn{}, nil
+}
+func (brokenRowsConnector) Driver() driver.Driver { return replayDriver{} }
+
+type brokenRowsConn struct{ replayConn }
+
+func (brokenRowsConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return brokenRows{}, nil
+}
+
+type brokenRows struct{}
+
+func (brokenRows) Columns() []string              { return []string{"id"} }
+func (brokenRows) Close() error                   { return nil }
+func (brokenRows) Next(dest []driver.Value) error { return driver.ErrBadConn }
@@ -0,0 +1,497 @@
+package dbc
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig configures query result caching.
+type CacheConfig struct {
+	// Backend stores cached results. An in-memory LRU cache with capacity of
+	// DefaultCacheSize results is used if not set.
+	Backend CacheBackend
+	// Tables maps names of cacheable tables to result TTLs. A query is cached
+	// only if all the tables it reads from are listed. If a query reads from
+	// several tables, the smallest TTL is used.
+	Tables map[string]time.Duration
+}
+
+// CacheBackend stores cached query results. Results are tagged with names of
+// the tables they were read from.
+type CacheBackend interface {
+	Get(key string) (res *CachedResult, ok bool)
+	Set(key string, res *CachedResult, ttl time.Duration, tags []string)
+	// Invalidate removes all results with any of the given tags.
+	Invalidate(tags ...string)
+}
+
+// CachedResult is a materialized query result.
+type CachedResult struct {
+	Columns     []string
+	ColumnTypes []string
+	Rows        [][]interface{}
+}
+
+// DefaultCacheSize is the capacity of the default cache backend.
+const DefaultCacheSize = 1000
+
+// tablesRegexp matches table names that follow keywords of reading and
+// writing statements. It doesn't understand SQL, so it finds extra names
+// sometimes, e.g. in `EXTRACT(YEAR FROM created_at)`. Extra names prevent
+// caching or cause extra invalidation, which is safe.
+var tablesRegexp = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE|TRUNCATE\\s+TABLE|TRUNCATE|TABLE)\\s+" +
+	"(" + tableNamePattern + ")")
+
+// uncacheableRegexp matches reading queries that tablesRegexp can't find all
+// tables of: subqueries and common table expressions. Such queries are never
+// cached, neither are queries with comma separated table lists, see
+// hasTableList.
+var uncacheableRegexp = regexp.MustCompile("(?i)\\(\\s*SELECT\\b|^\\s*WITH\\b")
+
+// fromClauseRegexp matches FROM clauses up to the next clause.
+var fromClauseRegexp = regexp.MustCompile("(?is)\\bFROM\\b(.*?)(?:\\b(?:WHERE|GROUP|HAVING|ORDER|LIMIT|UNION|WINDOW|FOR)\\b|;|$)")
+
+// tableNamePattern matches a table name with an optional schema name, both
+// optionally quoted.
+const tableNamePattern = "(?:[`\"]?[a-zA-Z_][a-zA-Z0-9_$]*[`\"]?\\.)?[`\"]?[a-zA-Z_][a-zA-Z0-9_$]*[`\"]?"
+
+// WithCache enables caching of query results. Results of queries that read
+// from cacheable tables are cached, writes to those tables invalidate cached
+// results. Transactions always read from the database.
+func WithCache(cfg CacheConfig) Option {
+	return func(c *dbWrapper) {
+		c.cache = newQueryCache(cfg)
+	}
+}
+
+type queryCache struct {
+	backend CacheBackend
+	tables  map[string]time.Duration
+
+	// mu guards versions and makes invalidation and storing of results
+	// atomic, see set.
+	mu sync.Mutex
+	// versions are incremented every time results of a table are
+	// invalidated.
+	versions map[string]uint64
+}
+
+func newQueryCache(cfg CacheConfig) *queryCache {
+	qc := &queryCache{
+		backend:  cfg.Backend,
+		tables:   make(map[string]time.Duration, len(cfg.Tables)),
+		versions: map[string]uint64{},
+	}
+	if qc.backend == nil {
+		qc.backend = NewLRUCache(DefaultCacheSize)
+	}
+	for t, ttl := range cfg.Tables {
+		qc.tables[strings.ToLower(t)] = ttl
+	}
+	return qc
+}
+
+// policy tells if a query result can be cached and returns its TTL and tags.
+// Queries that could read from tables that are not found are not cached.
+func (qc *queryCache) policy(query string) (ttl time.Duration, tags []string, ok bool) {
+	if uncacheableRegexp.MatchString(query) || hasTableList(query) {
+		return 0, nil, false
+	}
+	tags = queryTables(query)
+	if len(tags) == 0 {
+		return 0, nil, false
+	}
+	for i, t := range tags {
+		tttl, ok := qc.tables[t]
+		if !ok {
+			return 0, nil, false
+		}
+		if i == 0 || tttl < ttl {
+			ttl = tttl
+		}
+	}
+	return ttl, tags, true
+}
+
+func (qc *queryCache) invalidate(query string) []string {
+	tables := queryTables(query)
+	qc.invalidateTables(tables)
+	return tables
+}
+
+// invalidateTables removes cached results of tables.
+func (qc *queryCache) invalidateTables(tables []string) {
+	if len(tables) == 0 {
+		return
+	}
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	for _, t := range tables {
+		qc.versions[t]++
+	}
+	qc.backend.Invalidate(tables...)
+}
+
+// tagVersions returns current versions of tags. Versions must be obtained
+// before the query is made and passed to set.
+func (qc *queryCache) tagVersions(tags []string) []uint64 {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	vers := make([]uint64, len(tags))
+	for i, t := range tags {
+		vers[i] = qc.versions[t]
+	}
+	return vers
+}
+
+// set stores a query result unless any of its tags was invalidated after
+// versions were obtained, because the result could have been read before a
+// write.
+func (qc *queryCache) set(key string, res *CachedResult, ttl time.Duration, tags []string, vers []uint64) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	for i, t := range tags {
+		if qc.versions[t] != vers[i] {
+			return
+		}
+	}
+	qc.backend.Set(key, res, ttl, tags)
+}
+
+// hasTableList tells if a FROM clause of a query has comma separated tables,
+// only the first one of which is found by tablesRegexp.
+func hasTableList(query string) bool {
+	for _, m := range fromClauseRegexp.FindAllStringSubmatch(query, -1) {
+		var depth int
+		for _, c := range m[1] {
+			switch c {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// queryTables returns lowercase names of tables mentioned in a query. Schema
+// names and quotes are removed.
+func queryTables(query string) []string {
+	var tables []string
+	for _, m := range tablesRegexp.FindAllStringSubmatch(query, -1) {
+		name := m[1]
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[i+1:]
+		}
+		name = strings.ToLower(strings.Trim(name, "`\""))
+		tables = appendUnique(tables, name)
+	}
+	return tables
+}
+
+// touch records tables that a transaction wrote to.
+func (c *caller) touch(tables []string) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	for _, t := range tables {
+		c.touched = appendUnique(c.touched, t)
+	}
+}
+
+func (c *caller) touchedTables() []string {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	return c.touched
+}
+
+// cacheKey returns a key of a query result. Arguments are keyed by the
+// values that are sent to the database. It returns false if an argument
+// can't be converted into such value. Query is keyed as is because
+// whitespace is significant inside of string literals.
+func cacheKey(e *QueryEvent) (string, bool) {
+	h := sha1.New()
+	io.WriteString(h, e.Query)
+	for _, arg := range e.Args {
+		var name string
+		if na, ok := arg.(sql.NamedArg); ok {
+			name, arg = na.Name, na.Value
+		}
+		v, err := cacheKeyValue(arg)
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(h, "\x00%s\x00%T:%v", name, v, v)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// cacheKeyValue dereferences pointers and calls driver.Valuer, so that values
+// behind different pointers produce the same key.
+func cacheKeyValue(arg interface{}) (interface{}, error) {
+	for {
+		val := reflect.ValueOf(arg)
+		if val.Kind() == reflect.Ptr && val.IsNil() {
+			return nil, nil
+		}
+		if v, ok := arg.(driver.Valuer); ok {
+			var err error
+			if arg, err = v.Value(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if t, ok := arg.(time.Time); ok {
+			// Monotonic clock reading is printed by %v.
+			return t.UTC().Format(time.RFC3339Nano), nil
+		}
+		if val.Kind() != reflect.Ptr {
+			return arg, nil
+		}
+		arg = val.Elem().Interface()
+	}
+}
+
+// cachedQuery serves a query from cache. On cache miss the query is executed,
+// its result is read into memory and stored.
+func (c *caller) cachedQuery(ctx context.Context, e *QueryEvent, ttl time.Duration, tags []string) Rows {
+	key, ok := cacheKey(e)
+	if !ok {
+		return c.query(ctx, e)
+	}
+	res, ok := c.cache.backend.Get(key)
+	if !ok {
+		vers := c.cache.tagVersions(tags)
+		r := c.query(ctx, e)
+		if r.Error() != nil {
+			return r
+		}
+		var err error
+		res, err = materialize(r.Rows())
+		if err != nil {
+			return &rows{err: r.readFailed(err)}
+		}
+		c.cache.set(key, res, ttl, tags, vers)
+	}
+
+	rr, err := replayDB.QueryContext(ctx, "", res)
+	return &rows{
+		err:    err,
+		rows:   rr,
+		flavor: c.flavor,
+	}
+}
+
+func materialize(r *sql.Rows) (*CachedResult, error) {
+	defer r.Close()
+	cols, err := r.Columns()
+	if err != nil {
+		return nil, err
+	}
+	colTypes, err := r.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	res := &CachedResult{
+		Columns:     cols,
+		ColumnTypes: make([]string, len(cols)),
+	}
+	for i, ct := range colTypes {
+		res.ColumnTypes[i] = ct.DatabaseTypeName()
+	}
+	for r.Next() {
+		row := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := r.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, r.Err()
+}
+
+//
+// Replay
+//
+
+// replayDB turns cached results back into *sql.Rows, so that they are loaded
+// with the same conversion rules as results returned by a database.
+var replayDB = sql.OpenDB(replayConnector{})
+
+type replayConnector struct{}
+
+func (replayConnector) Connect(context.Context) (driver.Conn, error) { return replayConn{}, nil }
+func (replayConnector) Driver() driver.Driver                        { return replayDriver{} }
+
+type replayDriver struct{}
+
+func (replayDriver) Open(string) (driver.Conn, error) { return replayConn{}, nil }
+
+type replayConn struct{}
+
+func (replayConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("Prepared statements are not supported")
+}
+
+func (replayConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("Transactions are not supported")
+}
+
+func (replayConn) Close() error { return nil }
+
+// CheckNamedValue allows passing a cached result as a query argument.
+func (replayConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (replayConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	return &replayRows{res: args[0].Value.(*CachedResult)}, nil
+}
+
+type replayRows struct {
+	res *CachedResult
+	i   int
+}
+
+func (r *replayRows) Columns() []string { return r.res.Columns }
+func (r *replayRows) Close() error      { return nil }
+
+func (r *replayRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.Rows) {
+		return io.EOF
+	}
+	for i, v := range r.res.Rows[r.i] {
+		dest[i] = v
+	}
+	r.i++
+	return nil
+}
+
+func (r *replayRows) ColumnTypeDatabaseTypeName(i int) string {
+	return r.res.ColumnTypes[i]
+}
+
+//
+// LRU
+//
+
+// LRUCache is an in-memory cache backend that evicts least recently used
+// results when it is full.
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+}
+
+type lruEntry struct {
+	key       string
+	res       *CachedResult
+	expiresAt time.Time
+	tags      []string
+}
+
+var _ CacheBackend = &LRUCache{}
+
+// NewLRUCache creates an in-memory cache backend of a given capacity.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+	}
+}
+
+// Get returns a cached result if it exists and has not expired.
+func (c *LRUCache) Get(key string) (*CachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := el.Value.(*lruEntry)
+	if time.Now().After(ent.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return ent.res, true
+}
+
+// Set stores a result.
+func (c *LRUCache) Set(key string, res *CachedResult, ttl time.Duration, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	ent := &lruEntry{
+		key:       key,
+		res:       res,
+		expiresAt: time.Now().Add(ttl),
+		tags:      tags,
+	}
+	c.items[key] = c.ll.PushFront(ent)
+	for _, t := range tags {
+		if _, ok := c.tags[t]; !ok {
+			c.tags[t] = make(map[string]struct{})
+		}
+		c.tags[t][key] = struct{}{}
+	}
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Invalidate removes results with any of the given tags.
+func (c *LRUCache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range tags {
+		for key := range c.tags[t] {
+			if el, ok := c.items[key]; ok {
+				c.remove(el)
+			}
+		}
+	}
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	ent := el.Value.(*lruEntry)
+	c.ll.Remove(el)
+	delete(c.items, ent.key)
+	for _, t := range ent.tags {
+		delete(c.tags[t], ent.key)
+		if len(c.tags[t]) == 0 {
+			delete(c.tags, t)
+		}
+	}
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
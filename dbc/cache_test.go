@@ -0,0 +1,224 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestQueryTables(t *testing.T) {
+	cases := map[string][]string{
+		"SELECT * FROM users u JOIN `app`.`Roles` r ON r.id = u.role_id": {"users", "roles"},
+		`INSERT INTO "settings" (k, v) VALUES (?, ?)`:                    {"settings"},
+		"UPDATE settings SET v = ? WHERE k = ?":                          {"settings"},
+		"DELETE FROM settings WHERE k = ?":                               {"settings"},
+		"TRUNCATE TABLE settings":                                        {"settings"},
+		"SELECT * FROM (SELECT id FROM users) AS t":                      {"users"},
+		"SELECT 1": nil,
+	}
+	for q, exp := range cases {
+		if out := queryTables(q); !cmp.Equal(exp, out) {
+			t.Errorf("Tables of query %q don't match: %s", q, cmp.Diff(exp, out))
+		}
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	qc := newQueryCache(CacheConfig{Tables: map[string]time.Duration{
+		"settings": time.Minute,
+		"Roles":    time.Second,
+	}})
+	ttl, tags, ok := qc.policy("SELECT * FROM settings s JOIN roles r ON r.id = s.role_id")
+	if !ok {
+		t.Fatal("Expected query to be cacheable")
+	}
+	if ttl != time.Second {
+		t.Errorf("Expected TTL to be 1s, got %v", ttl)
+	}
+	if exp := []string{"settings", "roles"}; !cmp.Equal(exp, tags) {
+		t.Errorf("Tags don't match: %s", cmp.Diff(exp, tags))
+	}
+	if _, _, ok := qc.policy("SELECT * FROM settings JOIN users"); ok {
+		t.Error("Expected query that reads from a non-cacheable table to not be cached")
+	}
+	for _, q := range []string{
+		"SELECT * FROM settings, users WHERE users.id = settings.user_id",
+		"SELECT * FROM settings AS s , users u",
+		"SELECT * FROM settings s JOIN roles r ON r.id = s.role_id, users",
+		"SELECT * FROM settings WHERE role_id IN ( SELECT id FROM users )",
+		"WITH s AS (SELECT * FROM users) SELECT * FROM settings",
+	} {
+		if _, _, ok := qc.policy(q); ok {
+			t.Errorf("Expected query %q that can't be fully parsed to not be cached", q)
+		}
+	}
+	for _, q := range []string{
+		"SELECT * FROM settings WHERE id IN (1, 2)",
+		"SELECT a, b FROM settings ORDER BY a, b",
+	} {
+		if _, _, ok := qc.policy(q); !ok {
+			t.Errorf("Expected query %q to be cached", q)
+		}
+	}
+}
+
+func TestCacheSkipsInvalidatedResults(t *testing.T) {
+	backend := NewLRUCache(10)
+	qc := newQueryCache(CacheConfig{Backend: backend})
+	res := &CachedResult{Columns: []string{"id"}}
+
+	vers := qc.tagVersions([]string{"settings"})
+	// A write happens while the result is being read.
+	qc.invalidate("UPDATE settings SET v = 1")
+	qc.set("stale", res, time.Minute, []string{"settings"}, vers)
+	if _, ok := backend.Get("stale"); ok {
+		t.Error("Expected result read before invalidation to not be stored")
+	}
+
+	vers = qc.tagVersions([]string{"settings"})
+	qc.invalidate("UPDATE users SET v = 1")
+	qc.set("fresh", res, time.Minute, []string{"settings"}, vers)
+	if _, ok := backend.Get("fresh"); !ok {
+		t.Error("Expected result to be stored")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	key := func(args ...interface{}) string {
+		t.Helper()
+		k, ok := cacheKey(&QueryEvent{Query: "SELECT * FROM settings WHERE id = ?", Args: args})
+		if !ok {
+			t.Fatalf("Expected arguments %v to produce a key", args)
+		}
+		return k
+	}
+	one, two := 1, 2
+	if key(&one) != key(1) {
+		t.Error("Expected pointer argument to be keyed by its value")
+	}
+	if key(&one) == key(&two) {
+		t.Error("Expected different values to produce different keys")
+	}
+	if key(sql.NullInt64{Int64: 1, Valid: true}) != key(int64(1)) {
+		t.Error("Expected driver.Valuer argument to be keyed by its value")
+	}
+	var nilPtr *int
+	if key(nilPtr) != key(nil) {
+		t.Error("Expected nil pointer to be keyed as NULL")
+	}
+	now := time.Now()
+	if key(now) != key(now.Round(0)) {
+		t.Error("Expected monotonic clock reading to be ignored")
+	}
+	if key(sql.Named("id", 1)) == key(sql.Named("other", 1)) {
+		t.Error("Expected names of named arguments to be keyed")
+	}
+
+	k1, _ := cacheKey(&QueryEvent{Query: "SELECT * FROM settings WHERE name = 'a  b'"})
+	k2, _ := cacheKey(&QueryEvent{Query: "SELECT * FROM settings WHERE name = 'a b'"})
+	if k1 == k2 {
+		t.Error("Expected whitespace inside of string literals to be keyed")
+	}
+}
+
+func TestTouchConcurrent(t *testing.T) {
+	c := &caller{inTx: true}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.touch([]string{"t" + strconv.Itoa(i%3)})
+		}(i)
+	}
+	wg.Wait()
+	if n := len(c.touchedTables()); n != 3 {
+		t.Errorf("Expected 3 touched tables, got %d", n)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	res := &CachedResult{}
+	c.Set("a", res, time.Minute, []string{"t1"})
+	c.Set("b", res, time.Minute, []string{"t2"})
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Expected a to be cached")
+	}
+	c.Set("c", res, time.Minute, []string{"t2"})
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	c.Invalidate("t2")
+	if _, ok := c.Get("c"); ok {
+		t.Error("Expected c to be invalidated")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Expected a to stay cached")
+	}
+	c.Set("d", res, -time.Second, nil)
+	if _, ok := c.Get("d"); ok {
+		t.Error("Expected d to expire")
+	}
+}
+
+func TestCachedResultReplay(t *testing.T) {
+	res := &CachedResult{
+		Columns:     []string{"id", "name"},
+		ColumnTypes: []string{"INT", "VARCHAR"},
+		Rows: [][]interface{}{
+			{int64(1), []byte("Alice")},
+			{int64(2), []byte("Bob")},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		rr, err := replayDB.QueryContext(context.Background(), "", res)
+		var out []record
+		mustQuery(t, (&rows{err: err, rows: rr}).Load(&out))
+		exp := []record{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}}
+		if !cmp.Equal(exp, out) {
+			t.Errorf("Records don't match: %s", cmp.Diff(exp, out))
+		}
+	}
+}
+
+func TestCachedQuery(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	cconn, err := Connect(ctx, flavor, testDSN, WithCache(CacheConfig{
+		Tables: map[string]time.Duration{"sqldb_test": time.Minute},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer cconn.Close()
+	var queries int
+	cconn.After(func(context.Context, string, time.Duration, error) { queries++ })
+
+	load := func() []record {
+		var out []record
+		mustQuery(t, cconn.Query(ctx, "SELECT * FROM sqldb_test WHERE id = ?", 1).Load(&out))
+		return out
+	}
+	exp := []record{{ID: 1, Name: "Alice"}}
+	for i := 0; i < 2; i++ {
+		if out := load(); !cmp.Equal(exp, out) {
+			t.Errorf("Records don't match: %s", cmp.Diff(exp, out))
+		}
+	}
+	if queries != 1 {
+		t.Errorf("Expected one query to be executed, got %d", queries)
+	}
+
+	mustExec(t, cconn.Exec(ctx, "UPDATE sqldb_test SET name = 'Alice' WHERE id = 1"))
+	load()
+	if queries != 3 {
+		t.Errorf("Expected cache to be invalidated by update, got %d queries", queries)
+	}
+}
@@ -12,29 +12,50 @@ type (
 	// AfterCallback is a kind of function that can be called after a query was
 	// executed.
 	AfterCallback func(ctx context.Context, query string, took time.Duration, err error)
+	// QueryHook is a kind of function that is called with a description of a
+	// query before it is executed or after it was executed.
+	QueryHook func(ctx context.Context, e *QueryEvent)
 )
 
+// QueryEvent describes a query passed to callbacks.
+type QueryEvent struct {
+	Query string
+	Args  []interface{}
+	// ArgNames contains parameter names in the order of arguments if the query
+	// was made with named parameters.
+	ArgNames []string
+	// Took is the query execution time. It is only set after the query was
+	// executed.
+	Took time.Duration
+	// Err is an error returned by the query. It is only set after the query was
+	// executed.
+	Err error
+	// Plan is the query execution plan. It is only set for events passed to
+	// the plan handler of WithExplainSlow.
+	Plan string
+}
+
 type callbacks struct {
-	before []BeforeCallback
-	after  []AfterCallback
+	before []QueryHook
+	after  []QueryHook
 }
 
-func (c *callbacks) addBefore(cb BeforeCallback) {
+func (c *callbacks) addBefore(cb QueryHook) {
 	c.before = append(c.before, cb)
 }
 
-func (c *callbacks) addAfter(cb AfterCallback) {
+func (c *callbacks) addAfter(cb QueryHook) {
 	c.after = append(c.after, cb)
 }
 
-func (c *callbacks) callBefore(ctx context.Context, query string) {
+func (c *callbacks) callBefore(ctx context.Context, e *QueryEvent) {
 	for _, cb := range c.before {
-		cb(ctx, query)
+		cb(ctx, e)
 	}
 }
 
-func (c *callbacks) callAfter(ctx context.Context, query string, took time.Duration, err error) {
+func (c *callbacks) callAfter(ctx context.Context, e *QueryEvent) {
 	for _, cb := range c.after {
-		cb(ctx, query, took, err)
+		cb(ctx, e)
 	}
 }
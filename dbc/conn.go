@@ -3,6 +3,9 @@ package dbc
 import (
 	"context"
 	"database/sql"
+	"io"
+	"strconv"
+	"sync"
 
 	"github.com/juju/errors"
 )
@@ -12,7 +15,9 @@ type Conn interface {
 	connOrTx
 	// Begin executes a transaction.
 	Begin(context.Context, func(Tx) error) error
-	// BeginCustom executes a transaction with provided options.
+	// BeginCustom executes a transaction with provided options. If the context
+	// carries a transaction of this connection, see ContextWithTx, the
+	// function joins it instead and options are ignored.
 	BeginCustom(context.Context, func(Tx) error, *sql.TxOptions) error
 	// Close closes the connection.
 	Close() error
@@ -26,6 +31,53 @@ type Conn interface {
 	After(AfterCallback)
 }
 
+// DB is a database connection returned by Connect. It extends Conn with
+// features of this package. Code that only makes queries should depend on
+// Conn, which is easier to implement by wrappers and mocks.
+type DB interface {
+	Conn
+	// QueryPage executes a query using keyset pagination and returns a cursor
+	// for the next page.
+	QueryPage(ctx context.Context, q PageQuery, cursor string, dest interface{}) (next string, err error)
+	// BeginContext executes a transaction passing it to the function in the
+	// context. Queries made on the connection with that context join the
+	// transaction.
+	BeginContext(context.Context, func(context.Context) error) error
+	// BeforeQuery adds a hook that would be called before a query is
+	// executed.
+	BeforeQuery(QueryHook)
+	// AfterQuery adds a hook that would be called after a query was executed.
+	AfterQuery(QueryHook)
+	// Listen subscribes to a PostgreSQL notification channel. Notifications
+	// are received on a dedicated connection that is reestablished
+	// automatically. Returned channel is closed when the context is done or
+	// the connection is closed.
+	Listen(ctx context.Context, channel string) (<-chan Notification, error)
+	// Notify sends a notification to a PostgreSQL notification channel.
+	Notify(ctx context.Context, channel, payload string) error
+	// WithLock acquires a named lock, calls the function and releases the
+	// lock. It waits for the lock until the context is done. Named locks are
+	// supported by MySQL and PostgreSQL.
+	WithLock(ctx context.Context, name string, fn func() error) error
+	// TryWithLock is like WithLock but it returns ErrLockNotAcquired right
+	// away if the lock is held by another session.
+	TryWithLock(ctx context.Context, name string, fn func() error) error
+	// Tables returns names of tables in the current database or schema.
+	Tables(ctx context.Context) ([]string, error)
+	// Columns returns descriptions of table columns.
+	Columns(ctx context.Context, table string) ([]Column, error)
+	// Indexes returns descriptions of table indexes.
+	Indexes(ctx context.Context, table string) ([]Index, error)
+	// ExecScript splits a script into statements and executes them in order.
+	ExecScript(ctx context.Context, script io.Reader, opts ScriptOptions) ([]string, error)
+	// CopyFrom imports rows into a table using native bulk loading of
+	// PostgreSQL and MySQL.
+	CopyFrom(ctx context.Context, table string, columns []string, src CopySource) (int64, error)
+}
+
+// Option configures a connection, see Connect.
+type Option func(*dbWrapper)
+
 // Tx represents database transacation.
 type Tx interface {
 	connOrTx
@@ -35,7 +87,16 @@ type Tx interface {
 
 type dbWrapper struct {
 	conn *sql.DB
+	dsn  string
 	*caller
+
+	lmu      sync.Mutex
+	listener *listener
+	// explains tracks plans of slow queries that are being captured. New
+	// captures are not started once closed is set.
+	emu      sync.Mutex
+	closed   bool
+	explains sync.WaitGroup
 }
 
 // Flavor defines a kind of SQL database.
@@ -46,11 +107,35 @@ const (
 	MySQL Flavor = "mysql"
 	// PostgreSQL is the PostgreSQL SQL flavor.
 	PostgreSQL Flavor = "postgresql"
+	// SQLite is the SQLite SQL flavor.
+	SQLite Flavor = "sqlite"
 )
 
+// driverName returns the name under which the driver for the flavor is
+// registered in database/sql.
+func (f Flavor) driverName() string {
+	switch f {
+	case PostgreSQL:
+		return "postgres"
+	case SQLite:
+		return "sqlite3"
+	default:
+		return string(f)
+	}
+}
+
+// placeholder returns a positional parameter placeholder for the n-th
+// argument, starting from 1.
+func (f Flavor) placeholder(n int) string {
+	if f == PostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
 // Connect establishes a new database connection.
-func Connect(ctx context.Context, f Flavor, dsn string) (Conn, error) {
-	conn, err := sql.Open(string(f), dsn)
+func Connect(ctx context.Context, f Flavor, dsn string, opts ...Option) (DB, error) {
+	conn, err := sql.Open(f.driverName(), dsn)
 	if err != nil {
 		return nil, errors.Annotate(err, "Failed to establish connection")
 	}
@@ -58,13 +143,19 @@ func Connect(ctx context.Context, f Flavor, dsn string) (Conn, error) {
 	if err != nil {
 		return nil, errors.Annotate(err, "Connection is not responding")
 	}
-	return &dbWrapper{
+	c := &dbWrapper{
 		conn: conn,
+		dsn:  dsn,
 		caller: &caller{
-			db: conn,
-			cb: &callbacks{},
+			db:     conn,
+			cb:     &callbacks{},
+			flavor: f,
 		},
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
 }
 
 func (c *dbWrapper) Begin(ctx context.Context, fn func(tx Tx) error) error {
@@ -72,10 +163,18 @@ func (c *dbWrapper) Begin(ctx context.Context, fn func(tx Tx) error) error {
 }
 
 func (c *dbWrapper) BeginCustom(ctx context.Context, fn func(tx Tx) error, opts *sql.TxOptions) error {
+	if tx := c.ambientTx(ctx); tx != nil {
+		return fn(joinedTx{tx})
+	}
 	if opts == nil {
 		opts = &sql.TxOptions{}
 	}
+	gen, err := c.breaker.allow()
+	if err != nil {
+		return err
+	}
 	tx, err := c.conn.BeginTx(ctx, opts)
+	c.breaker.done(ctx, gen, err)
 	if err != nil {
 		return err
 	}
@@ -91,34 +190,75 @@ func (c *dbWrapper) DB() *sql.DB {
 }
 
 func (c *dbWrapper) Close() error {
+	c.lmu.Lock()
+	if c.listener != nil {
+		if err := c.listener.close(); err != nil {
+			c.lmu.Unlock()
+			return errors.Annotate(err, "Failed to close notification listener")
+		}
+		c.listener = nil
+	}
+	c.lmu.Unlock()
+	c.emu.Lock()
+	c.closed = true
+	c.emu.Unlock()
+	c.explains.Wait()
 	return c.conn.Close()
 }
 
 func (c *dbWrapper) Before(cb BeforeCallback) {
-	c.cb.addBefore(cb)
+	c.cb.addBefore(func(ctx context.Context, e *QueryEvent) {
+		cb(ctx, e.Query)
+	})
 }
 
 func (c *dbWrapper) After(cb AfterCallback) {
-	c.cb.addAfter(cb)
+	c.cb.addAfter(func(ctx context.Context, e *QueryEvent) {
+		cb(ctx, e.Query, e.Took, e.Err)
+	})
+}
+
+func (c *dbWrapper) BeforeQuery(h QueryHook) {
+	c.cb.addBefore(h)
+}
+
+func (c *dbWrapper) AfterQuery(h QueryHook) {
+	c.cb.addAfter(h)
 }
 
 func (c *dbWrapper) wrapTx(tx *sql.Tx) Tx {
 	return &txWrapper{
-		tx: tx,
-		connOrTx: &caller{
-			db: tx,
-			cb: c.cb,
+		tx:   tx,
+		conn: c,
+		caller: &caller{
+			db:      tx,
+			cb:      c.cb,
+			flavor:  c.flavor,
+			cache:   c.cache,
+			tags:    c.tags,
+			breaker: c.breaker,
+			inTx:    true,
 		},
 	}
 }
 
 type txWrapper struct {
-	tx *sql.Tx
-	connOrTx
+	tx   *sql.Tx
+	conn *dbWrapper
+	*caller
 }
 
 func (w *txWrapper) Commit() error {
-	return w.tx.Commit()
+	err := w.tx.Commit()
+	if err != nil || w.cache == nil {
+		return err
+	}
+	if touched := w.touchedTables(); len(touched) > 0 {
+		// Results could have been cached again while the transaction was in
+		// progress.
+		w.cache.invalidateTables(touched)
+	}
+	return nil
 }
 
 func (w *txWrapper) Rollback() error {
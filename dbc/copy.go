@@ -0,0 +1,315 @@
+package dbc
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/juju/errors"
+	"github.com/lib/pq"
+	"github.com/localhots/gobelt/csv2"
+)
+
+// CopySource provides rows for bulk import.
+type CopySource interface {
+	// Next returns values of the next row in the order of given columns. It
+	// returns io.EOF when there are no more rows.
+	Next(columns []string) ([]interface{}, error)
+}
+
+var copyHandlerSeq uint64
+
+// CopyFrom imports rows into a table using PostgreSQL COPY FROM STDIN or MySQL
+// LOAD DATA LOCAL INFILE. MySQL connection must allow local files with
+// allowAllFiles=true or it must be enabled on the server. It returns the
+// number of imported rows. If the context carries a transaction of this
+// connection, rows are imported inside of it.
+func (c *dbWrapper) CopyFrom(ctx context.Context, table string, columns []string, src CopySource) (int64, error) {
+	var n int64
+	var err error
+	switch c.flavor {
+	case PostgreSQL:
+		n, err = c.copyIn(ctx, table, columns, src)
+	case MySQL:
+		n, err = c.loadData(ctx, table, columns, src)
+	default:
+		return 0, errors.Errorf("Bulk import is not supported by %s flavor", c.flavor)
+	}
+	if c.cache != nil {
+		tables := []string{strings.ToLower(table)}
+		c.cache.invalidateTables(tables)
+		if tx := c.ambientTx(ctx); tx != nil {
+			tx.touch(tables)
+		}
+	}
+	return n, err
+}
+
+func (c *dbWrapper) copyIn(ctx context.Context, table string, columns []string, src CopySource) (int64, error) {
+	gen, err := c.breaker.allow()
+	if err != nil {
+		return 0, err
+	}
+	e := &QueryEvent{Query: pq.CopyIn(table, columns...)}
+	// The driver recognizes COPY statements by their prefix, so tags are
+	// appended instead.
+	query := e.Query
+	if cm := c.tags.comment(ctx); cm != "" {
+		query += " " + cm
+	}
+
+	c.cb.callBefore(ctx, e)
+	startedAt := time.Now()
+	var n int64
+	if tx := c.ambientTx(ctx); tx != nil {
+		n, err = copyInStmt(ctx, tx.tx, query, columns, src)
+	} else {
+		n, err = c.copyInTx(ctx, query, columns, src)
+	}
+	c.breaker.done(ctx, gen, err)
+	e.Took, e.Err = time.Since(startedAt), err
+	c.cb.callAfter(ctx, e)
+	return n, err
+}
+
+func (c *dbWrapper) copyInTx(ctx context.Context, query string, columns []string, src CopySource) (int64, error) {
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Annotate(err, "Failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	n, err := copyInStmt(ctx, tx, query, columns, src)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Annotate(err, "Failed to commit transaction")
+	}
+	return n, nil
+}
+
+func copyInStmt(ctx context.Context, tx *sql.Tx, query string, columns []string, src CopySource) (int64, error) {
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, errors.Annotate(err, "Failed to prepare COPY statement")
+	}
+	defer stmt.Close()
+
+	var n int64
+	for {
+		row, err := src.Next(columns)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, errors.Annotatef(err, "Failed to read row %d", n+1)
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return 0, errors.Annotatef(err, "Failed to copy row %d", n+1)
+		}
+		n++
+	}
+	// Empty exec flushes buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, errors.Annotate(err, "Failed to complete COPY")
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, errors.Annotate(err, "Failed to complete COPY")
+	}
+	return n, nil
+}
+
+// loadData streams rows to MySQL in the default LOAD DATA format: fields are
+// terminated by tabs, lines are terminated by newlines, special characters
+// are escaped with a backslash and NULL is written as \N. The query is made
+// with Exec, so it joins an ambient transaction.
+func (c *dbWrapper) loadData(ctx context.Context, table string, columns []string, src CopySource) (int64, error) {
+	name := "dbc_copy_" + strconv.FormatUint(atomic.AddUint64(&copyHandlerSeq, 1), 10)
+	pr, pw := io.Pipe()
+	mysql.RegisterReaderHandler(name, func() io.Reader { return pr })
+	defer mysql.DeregisterReaderHandler(name)
+
+	done := make(chan error, 1)
+	go func() {
+		err := writeLoadData(pw, columns, src)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	query := "LOAD DATA LOCAL INFILE 'Reader::" + name + "' INTO TABLE " + table +
+		" (" + strings.Join(columns, ", ") + ")"
+	res := c.Exec(ctx, query)
+	// Unblocks the writer if the query fails before the data is read. The
+	// source must not be used after CopyFrom returns, so the writer is
+	// waited for.
+	pr.Close()
+	werr := <-done
+	if werr == io.ErrClosedPipe {
+		werr = nil
+	}
+	if err := res.Error(); err != nil {
+		if werr != nil {
+			return 0, werr
+		}
+		return 0, err
+	}
+	if werr != nil {
+		return 0, werr
+	}
+	return res.RowsAffected(), nil
+}
+
+// writeLoadData writes rows until the source is exhausted or a write fails.
+// Errors of the buffered writer are sticky, so they are checked once a row.
+func writeLoadData(w io.Writer, columns []string, src CopySource) error {
+	bw := bufio.NewWriter(w)
+	for i := 1; ; i++ {
+		row, err := src.Next(columns)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Annotatef(err, "Failed to read row %d", i)
+		}
+		for j, v := range row {
+			if j > 0 {
+				bw.WriteByte('\t')
+			}
+			s, err := loadDataValue(v)
+			if err != nil {
+				return errors.Annotatef(err, "Failed to convert row %d", i)
+			}
+			bw.WriteString(s)
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+var loadDataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x00", `\0`,
+)
+
+func loadDataValue(v interface{}) (string, error) {
+	v, err := driver.DefaultParameterConverter.ConvertValue(v)
+	if err != nil {
+		return "", err
+	}
+	switch tv := v.(type) {
+	case nil:
+		return `\N`, nil
+	case []byte:
+		return loadDataEscaper.Replace(string(tv)), nil
+	case bool:
+		if tv {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return tv.Format("2006-01-02 15:04:05.999999"), nil
+	default:
+		return loadDataEscaper.Replace(fmt.Sprint(tv)), nil
+	}
+}
+
+//
+// Sources
+//
+
+type csvSource struct {
+	r *csv2.Reader
+}
+
+// CSVSource returns a copy source that reads rows from a CSV reader. Columns
+// are matched by names. All values are strings, columns that are missing in
+// the CSV file are NULL.
+func CSVSource(r *csv2.Reader) CopySource {
+	return &csvSource{r: r}
+}
+
+func (s *csvSource) Next(columns []string) ([]interface{}, error) {
+	m, err := s.r.ReadMap()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]interface{}, len(columns))
+	for i, col := range columns {
+		if v, ok := m[col]; ok {
+			row[i] = v
+		}
+	}
+	return row, nil
+}
+
+type structSource struct {
+	next func() (interface{}, error)
+	typ  reflect.Type
+	idx  map[string][]int
+}
+
+// StructSource returns a copy source that reads rows from structs or pointers
+// to structs returned by the iterator function. Columns are matched by `db`
+// field tags. Iterator must return io.EOF when there are no more rows.
+func StructSource(next func() (interface{}, error)) CopySource {
+	return &structSource{next: next}
+}
+
+// SliceSource returns a copy source that reads rows from a slice of structs.
+func SliceSource(slice interface{}) CopySource {
+	val := reflect.ValueOf(slice)
+	if val.Kind() != reflect.Slice {
+		panic("Value must be a slice")
+	}
+	i := 0
+	return StructSource(func() (interface{}, error) {
+		if i >= val.Len() {
+			return nil, io.EOF
+		}
+		i++
+		return val.Index(i - 1).Interface(), nil
+	})
+}
+
+func (s *structSource) Next(columns []string) ([]interface{}, error) {
+	v, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	val := reflect.Indirect(reflect.ValueOf(v))
+	if val.Kind() != reflect.Struct {
+		return nil, errors.Errorf("Unsupported row type: %T", v)
+	}
+	if val.Type() != s.typ {
+		s.typ = val.Type()
+		s.idx = paramFieldIndex(s.typ)
+	}
+	row := make([]interface{}, len(columns))
+	for i, col := range columns {
+		fi, ok := s.idx[col]
+		if !ok {
+			return nil, errors.Errorf("Struct %s has no field mapped to column %s", s.typ, col)
+		}
+		f, ok := fieldByIndex(val, fi)
+		if !ok {
+			return nil, errors.Errorf("Field mapped to column %s belongs to a nil embedded struct", col)
+		}
+		row[i] = f.Interface()
+	}
+	return row, nil
+}
@@ -0,0 +1,157 @@
+package dbc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localhots/gobelt/context2"
+	"github.com/localhots/gobelt/csv2"
+)
+
+type copyRecord struct {
+	ID   int     `db:"id"`
+	Name *string `db:"name"`
+}
+
+func TestWriteLoadData(t *testing.T) {
+	name := "Tab\there\\"
+	src := SliceSource([]copyRecord{
+		{ID: 1, Name: &name},
+		{ID: 2},
+	})
+	var buf bytes.Buffer
+	if err := writeLoadData(&buf, []string{"name", "id"}, src); err != nil {
+		t.Fatalf("Failed to write data: %v", err)
+	}
+	const exp = "Tab\\there\\\\\t1\n\\N\t2\n"
+	if buf.String() != exp {
+		t.Errorf("Expected data to be\n%q\ngot\n%q", exp, buf.String())
+	}
+}
+
+func TestCSVSource(t *testing.T) {
+	body := "name,id\nAlice,1\nBob,2\n"
+	src := CSVSource(csv2.NewReader(csv.NewReader(strings.NewReader(body))))
+	var buf bytes.Buffer
+	if err := writeLoadData(&buf, []string{"id", "name", "email"}, src); err != nil {
+		t.Fatalf("Failed to write data: %v", err)
+	}
+	const exp = "1\tAlice\t\\N\n2\tBob\t\\N\n"
+	if buf.String() != exp {
+		t.Errorf("Expected data to be\n%q\ngot\n%q", exp, buf.String())
+	}
+}
+
+func TestStructSourceMissingColumn(t *testing.T) {
+	src := SliceSource([]copyRecord{{ID: 1}})
+	if _, err := src.Next([]string{"id", "email"}); err == nil {
+		t.Error("Expected missing column error")
+	}
+}
+
+func TestStructSourceEmbeddedPointer(t *testing.T) {
+	src := SliceSource([]namedRecordExported{
+		{NamedAudit: &NamedAudit{CreatedBy: "alice"}, ID: 1},
+		{ID: 2},
+	})
+	row, err := src.Next([]string{"id", "created_by"})
+	if err != nil {
+		t.Fatalf("Failed to read row: %v", err)
+	}
+	if row[0] != 1 || row[1] != "alice" {
+		t.Errorf("Unexpected row: %v", row)
+	}
+	if _, err := src.Next([]string{"id", "created_by"}); err == nil {
+		t.Error("Expected nil embedded struct error")
+	}
+}
+
+// countingSource returns numbered rows until the limit is reached. Zero limit
+// means no limit.
+type countingSource struct {
+	calls int
+	limit int
+}
+
+func (s *countingSource) Next([]string) ([]interface{}, error) {
+	if s.limit > 0 && s.calls >= s.limit {
+		return nil, io.EOF
+	}
+	s.calls++
+	return []interface{}{s.calls}, nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("Write failed") }
+
+func TestWriteLoadDataWriteError(t *testing.T) {
+	const limit = 100000
+	src := &countingSource{limit: limit}
+	if err := writeLoadData(failingWriter{}, []string{"id"}, src); err == nil {
+		t.Error("Expected write error")
+	}
+	if src.calls == limit {
+		t.Error("Expected writer to stop on the first error")
+	}
+}
+
+// failingExecer fails queries without reading LOAD DATA contents.
+type failingExecer struct{}
+
+func (failingExecer) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errors.New("Exec failed")
+}
+
+func (failingExecer) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("Query failed")
+}
+
+func TestLoadDataWaitsForWriter(t *testing.T) {
+	c := &dbWrapper{caller: &caller{db: failingExecer{}, cb: &callbacks{}, flavor: MySQL}}
+	src := &countingSource{}
+	if _, err := c.CopyFrom(context.Background(), "tbl", []string{"id"}, src); err == nil {
+		t.Fatal("Expected import to fail")
+	}
+	calls := src.calls
+	time.Sleep(10 * time.Millisecond)
+	if src.calls != calls {
+		t.Error("Source was read after CopyFrom returned")
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	src := SliceSource([]copyRecord{{ID: 1}, {ID: 2}})
+	if flavor != MySQL && flavor != PostgreSQL {
+		if _, err := conn.CopyFrom(ctx, "copy_test", []string{"id", "name"}, src); err == nil {
+			t.Error("Expected CopyFrom to fail")
+		}
+		return
+	}
+
+	mustExec(t, conn.Exec(ctx, "DROP TABLE IF EXISTS copy_test"))
+	mustExec(t, conn.Exec(ctx, "CREATE TABLE copy_test (id INT NOT NULL, name VARCHAR(10))"))
+	defer conn.Exec(ctx, "DROP TABLE copy_test")
+
+	n, err := conn.CopyFrom(ctx, "copy_test", []string{"id", "name"}, src)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 rows to be imported, got %d", n)
+	}
+	var ids []int
+	mustQuery(t, conn.Query(ctx, "SELECT id FROM copy_test ORDER BY id").Load(&ids))
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Unexpected imported rows: %v", ids)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	_ "github.com/lib/pq"              // PostgreSQL driver
+	"github.com/localhots/gobelt/dbc"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type table struct {
+	Name       string
+	StructName string
+	Fields     []field
+	CRUD       bool
+}
+
+type field struct {
+	Name          string
+	Column        string
+	Type          string
+	PrimaryKey    bool
+	AutoIncrement bool
+}
+
+func main() {
+	flavor := flag.String("flavor", string(dbc.MySQL), "Database flavor: mysql, postgresql or sqlite")
+	dsn := flag.String("dsn", "", "Database source name")
+	tables := flag.String("tables", "", "Comma separated list of tables")
+	pkg := flag.String("pkg", "models", "Package name")
+	out := flag.String("out", "", "Output file, standard output is used if not specified")
+	crud := flag.Bool("crud", false, "Generate CRUD helpers")
+	flag.Parse()
+
+	if *dsn == "" {
+		log.Println("Database source name is not specified")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *tables == "" {
+		log.Println("Tables are not specified")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	conn, err := dbc.Connect(ctx, dbc.Flavor(*flavor), *dsn)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var tbls []table
+	for _, name := range strings.Split(*tables, ",") {
+		name = strings.TrimSpace(name)
+		cols, err := conn.Columns(ctx, name)
+		if err != nil {
+			log.Fatalf("Failed to describe table %s: %v", name, err)
+		}
+		if len(cols) == 0 {
+			log.Fatalf("Table %s does not exist", name)
+		}
+		tbls = append(tbls, newTable(name, cols, *crud))
+	}
+
+	src, err := render(*pkg, tbls)
+	if err != nil {
+		log.Fatalf("Failed to generate code: %v", err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	err = ioutil.WriteFile(*out, src, 0644)
+	if err != nil {
+		log.Fatalf("Failed to write file %s: %v", *out, err)
+	}
+}
+
+func newTable(name string, cols []dbc.Column, crud bool) table {
+	t := table{
+		Name:       name,
+		StructName: singular(camelCase(name)),
+		CRUD:       crud,
+	}
+	for _, col := range cols {
+		t.Fields = append(t.Fields, field{
+			Name:          camelCase(col.Name),
+			Column:        col.Name,
+			Type:          goType(col),
+			PrimaryKey:    col.PrimaryKey,
+			AutoIncrement: col.AutoIncrement,
+		})
+	}
+	return t
+}
+
+func render(pkg string, tbls []table) ([]byte, error) {
+	var imports []string
+	var crud bool
+	for _, t := range tbls {
+		crud = crud || t.CRUD
+		for _, f := range t.Fields {
+			if strings.Contains(f.Type, "time.Time") {
+				imports = appendOnce(imports, "time")
+			}
+		}
+	}
+	if crud {
+		imports = appendOnce(imports, "context")
+	}
+	sort.Strings(imports)
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Imports": imports,
+		"Tables":  tbls,
+		"CRUD":    crud,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+var goTypes = map[string]string{
+	"tinyint":     "int8",
+	"smallint":    "int16",
+	"mediumint":   "int32",
+	"int":         "int32",
+	"integer":     "int64",
+	"bigint":      "int64",
+	"year":        "int16",
+	"int2":        "int16",
+	"int4":        "int32",
+	"int8":        "int64",
+	"float":       "float32",
+	"float4":      "float32",
+	"double":      "float64",
+	"real":        "float64",
+	"float8":      "float64",
+	"bool":        "bool",
+	"boolean":     "bool",
+	"date":        "time.Time",
+	"datetime":    "time.Time",
+	"timestamp":   "time.Time",
+	"timestamptz": "time.Time",
+	"binary":      "[]byte",
+	"varbinary":   "[]byte",
+	"tinyblob":    "[]byte",
+	"blob":        "[]byte",
+	"mediumblob":  "[]byte",
+	"longblob":    "[]byte",
+	"bytea":       "[]byte",
+}
+
+// goType returns a Go type for a column. Types that are not known are mapped
+// to strings, this includes decimals. Nullable columns are mapped to pointers,
+// byte slices are nullable on their own.
+func goType(col dbc.Column) string {
+	typ, ok := goTypes[col.Type]
+	if !ok {
+		typ = "string"
+	}
+	switch {
+	case col.FullType == "tinyint(1)":
+		typ = "bool"
+	case strings.HasPrefix(typ, "int") && strings.Contains(col.FullType, "unsigned"):
+		typ = "u" + typ
+	}
+	if col.Nullable && typ != "[]byte" {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+var initialisms = map[string]string{
+	"api":  "API",
+	"http": "HTTP",
+	"id":   "ID",
+	"ip":   "IP",
+	"json": "JSON",
+	"sql":  "SQL",
+	"url":  "URL",
+	"uuid": "UUID",
+}
+
+func camelCase(name string) string {
+	var buf bytes.Buffer
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' ' || r == '.'
+	}) {
+		part = strings.ToLower(part)
+		if in, ok := initialisms[part]; ok {
+			buf.WriteString(in)
+		} else {
+			buf.WriteString(strings.ToUpper(part[:1]))
+			buf.WriteString(part[1:])
+		}
+	}
+	return buf.String()
+}
+
+// singular is a naive conversion of plural table names into struct names.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ss"):
+		return name
+	case strings.HasSuffix(name, "s"):
+		return strings.TrimSuffix(name, "s")
+	default:
+		return name
+	}
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{
+	"columns":  columnList,
+	"params":   paramList,
+	"insert":   insertFields,
+	"update":   updateFields,
+	"keys":     keyFields,
+	"keyWhere": keyWhere,
+	"keyArgs":  keyArgs,
+	"keyMap":   keyMap,
+}).Parse(`/*******************************************************************************
+THIS FILE WAS AUTOMATICALLY GENERATED. DO NOT EDIT!
+*******************************************************************************/
+
+package {{ .Package }}
+{{ if .Imports }}
+import (
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
+{{- if .CRUD }}
+
+	"github.com/localhots/gobelt/dbc"
+{{- end }}
+)
+{{ end }}
+{{- if .CRUD }}
+// Queryer is implemented by dbc connections and transactions.
+type Queryer interface {
+	ExecNamed(ctx context.Context, query string, arg interface{}) dbc.ExecResult
+	QueryNamed(ctx context.Context, query string, arg interface{}) dbc.Rows
+}
+{{ end }}
+{{- range $t := .Tables }}
+// {{ $t.StructName }} represents a row of {{ $t.Name }} table.
+type {{ $t.StructName }} struct {
+{{- range $t.Fields }}
+	{{ .Name }} {{ .Type }} ` + "`db:\"{{ .Column }}\"`" + `
+{{- end }}
+}
+{{ if $t.CRUD }}
+// Insert{{ $t.StructName }} inserts a row into {{ $t.Name }} table.
+func Insert{{ $t.StructName }}(ctx context.Context, db Queryer, r *{{ $t.StructName }}) dbc.ExecResult {
+	return db.ExecNamed(ctx, "INSERT INTO {{ $t.Name }} ({{ columns (insert $t) }}) VALUES ({{ params (insert $t) }})", r)
+}
+{{ if keys $t }}
+// Get{{ $t.StructName }} loads a row from {{ $t.Name }} table by its primary key.
+// It returns nil if the row does not exist.
+func Get{{ $t.StructName }}(ctx context.Context, db Queryer, {{ keyArgs $t }}) (*{{ $t.StructName }}, error) {
+	var rs []{{ $t.StructName }}
+	err := db.QueryNamed(ctx, "SELECT {{ columns $t.Fields }} FROM {{ $t.Name }} WHERE {{ keyWhere $t }}", {{ keyMap $t }}).Load(&rs)
+	if err != nil || len(rs) == 0 {
+		return nil, err
+	}
+	return &rs[0], nil
+}
+{{ if update $t }}
+// Update{{ $t.StructName }} updates a row in {{ $t.Name }} table by its primary key.
+func Update{{ $t.StructName }}(ctx context.Context, db Queryer, r *{{ $t.StructName }}) dbc.ExecResult {
+	return db.ExecNamed(ctx, "UPDATE {{ $t.Name }} SET {{ range $i, $f := update $t }}{{ if $i }}, {{ end }}{{ $f.Column }} = @{{ $f.Column }}{{ end }} WHERE {{ keyWhere $t }}", r)
+}
+{{ end }}
+// Delete{{ $t.StructName }} deletes a row from {{ $t.Name }} table by its primary key.
+func Delete{{ $t.StructName }}(ctx context.Context, db Queryer, {{ keyArgs $t }}) dbc.ExecResult {
+	return db.ExecNamed(ctx, "DELETE FROM {{ $t.Name }} WHERE {{ keyWhere $t }}", {{ keyMap $t }})
+}
+{{ end }}
+{{- end }}
+{{- end }}
+`))
+
+func columnList(fields []field) string {
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = f.Column
+	}
+	return strings.Join(cols, ", ")
+}
+
+func paramList(fields []field) string {
+	params := make([]string, len(fields))
+	for i, f := range fields {
+		params[i] = "@" + f.Column
+	}
+	return strings.Join(params, ", ")
+}
+
+func insertFields(t table) []field {
+	var fields []field
+	for _, f := range t.Fields {
+		if !f.AutoIncrement {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func updateFields(t table) []field {
+	var fields []field
+	for _, f := range t.Fields {
+		if !f.PrimaryKey {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func keyFields(t table) []field {
+	var fields []field
+	for _, f := range t.Fields {
+		if f.PrimaryKey {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func keyWhere(t table) string {
+	var conds []string
+	for _, f := range keyFields(t) {
+		conds = append(conds, f.Column+" = @"+f.Column)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func keyArgs(t table) string {
+	var args []string
+	for _, f := range keyFields(t) {
+		args = append(args, argName(f)+" "+strings.TrimPrefix(f.Type, "*"))
+	}
+	return strings.Join(args, ", ")
+}
+
+func keyMap(t table) string {
+	var pairs []string
+	for _, f := range keyFields(t) {
+		pairs = append(pairs, `"`+f.Column+`": `+argName(f))
+	}
+	return "map[string]interface{}{" + strings.Join(pairs, ", ") + "}"
+}
+
+func argName(f field) string {
+	name := f.Name
+	if in, ok := initialisms[strings.ToLower(name)]; ok && in == name {
+		name = strings.ToLower(name)
+	} else {
+		name = strings.ToLower(name[:1]) + name[1:]
+	}
+	if token.IsKeyword(name) {
+		name += "_"
+	}
+	return name
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/localhots/gobelt/dbc"
+)
+
+var update = flag.Bool("update", false, "Update golden files")
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"name":          "Name",
+		"user_id":       "UserID",
+		"api_key":       "APIKey",
+		"created-at":    "CreatedAt",
+		"UPPER_CASE":    "UpperCase",
+		"schema.table":  "SchemaTable",
+		"json_data_url": "JSONDataURL",
+	}
+	for in, exp := range tests {
+		if out := camelCase(in); out != exp {
+			t.Errorf("Expected %q to be converted into %q, got %q", in, exp, out)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := map[string]string{
+		"Users":      "User",
+		"Categories": "Category",
+		"Address":    "Address",
+		"Data":       "Data",
+	}
+	for in, exp := range tests {
+		if out := singular(in); out != exp {
+			t.Errorf("Expected %q to be converted into %q, got %q", in, exp, out)
+		}
+	}
+}
+
+func TestGoType(t *testing.T) {
+	tests := []struct {
+		col dbc.Column
+		exp string
+	}{
+		{dbc.Column{Type: "int", FullType: "int(11)"}, "int32"},
+		{dbc.Column{Type: "int", FullType: "int(10) unsigned"}, "uint32"},
+		{dbc.Column{Type: "bigint", FullType: "bigint(20)", Nullable: true}, "*int64"},
+		{dbc.Column{Type: "tinyint", FullType: "tinyint(1)"}, "bool"},
+		{dbc.Column{Type: "int8", FullType: "bigint"}, "int64"},
+		{dbc.Column{Type: "varchar", FullType: "varchar(255)"}, "string"},
+		{dbc.Column{Type: "decimal", FullType: "decimal(10,2)"}, "string"},
+		{dbc.Column{Type: "timestamptz", FullType: "timestamp with time zone", Nullable: true}, "*time.Time"},
+		{dbc.Column{Type: "blob", FullType: "blob", Nullable: true}, "[]byte"},
+		{dbc.Column{Type: "double", FullType: "double"}, "float64"},
+	}
+	for _, test := range tests {
+		if out := goType(test.col); out != test.exp {
+			t.Errorf("Expected %s column to be mapped to %s, got %s", test.col.FullType, test.exp, out)
+		}
+	}
+}
+
+func TestArgName(t *testing.T) {
+	tests := map[string]string{
+		"ID":     "id",
+		"UserID": "userID",
+		"Type":   "type_",
+	}
+	for in, exp := range tests {
+		if out := argName(field{Name: in}); out != exp {
+			t.Errorf("Expected argument name for %q to be %q, got %q", in, exp, out)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tbls := []table{
+		newTable("users", []dbc.Column{
+			{Name: "id", Type: "bigint", FullType: "bigint(20) unsigned", PrimaryKey: true, AutoIncrement: true},
+			{Name: "email", Type: "varchar", FullType: "varchar(255)"},
+			{Name: "type", Type: "varchar", FullType: "varchar(32)"},
+			{Name: "created_at", Type: "datetime", FullType: "datetime"},
+			{Name: "deleted_at", Type: "datetime", FullType: "datetime", Nullable: true},
+		}, true),
+		newTable("user_roles", []dbc.Column{
+			{Name: "user_id", Type: "bigint", FullType: "bigint(20) unsigned", PrimaryKey: true},
+			{Name: "role", Type: "varchar", FullType: "varchar(32)", PrimaryKey: true},
+		}, true),
+		newTable("audit_log", []dbc.Column{
+			{Name: "message", Type: "text", FullType: "text"},
+			{Name: "payload", Type: "blob", FullType: "blob", Nullable: true},
+		}, false),
+	}
+	src, err := render("models", tbls)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "models.go.golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	exp, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(exp, src) {
+		t.Errorf("Generated code doesn't match %s, run tests with -update to update it:\n%s", golden, src)
+	}
+}
@@ -0,0 +1,157 @@
+// Package dbctest provides helpers for tests that use a database.
+package dbctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/localhots/gobelt/context2"
+	"github.com/localhots/gobelt/csv2"
+	"github.com/localhots/gobelt/dbc"
+	"gopkg.in/yaml.v2"
+)
+
+// Execer is implemented by dbc connections and transactions.
+type Execer interface {
+	ExecNamed(ctx context.Context, query string, arg interface{}) dbc.ExecResult
+}
+
+var errRollback = errors.New("Test transaction rolled back")
+
+// Begin starts a transaction that is rolled back when the test and all its
+// subtests complete, and loads given fixture files inside of it. It returns a
+// test context and the transaction.
+func Begin(t *testing.T, conn dbc.Conn, fixtures ...string) (context.Context, dbc.Tx) {
+	t.Helper()
+	ctx := context2.TestContext(t)
+
+	txc := make(chan dbc.Tx)
+	done := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- conn.Begin(ctx, func(tx dbc.Tx) error {
+			txc <- tx
+			<-done
+			return errRollback
+		})
+	}()
+
+	var tx dbc.Tx
+	select {
+	case tx = <-txc:
+	case err := <-errc:
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		close(done)
+		if err := <-errc; err != errRollback {
+			t.Errorf("Failed to roll back transaction: %v", err)
+		}
+	})
+
+	if err := LoadFixtures(ctx, tx, fixtures...); err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	return ctx, tx
+}
+
+// LoadFixtures inserts rows from fixture files into tables. Table name is the
+// name of a file without extension. YAML and JSON files must contain a list of
+// objects that map column names to values. CSV files must have column names in
+// the first row, all values are inserted as strings.
+func LoadFixtures(ctx context.Context, db Execer, paths ...string) error {
+	for _, path := range paths {
+		rows, err := readFixture(path)
+		if err != nil {
+			return errors.Annotatef(err, "Failed to read fixture file %s", path)
+		}
+		table := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		for i, row := range rows {
+			err := db.ExecNamed(ctx, insertQuery(table, row), row).Error()
+			if err != nil {
+				return errors.Annotatef(err, "Failed to insert row %d from fixture file %s", i+1, path)
+			}
+		}
+	}
+	return nil
+}
+
+func readFixture(path string) ([]map[string]interface{}, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yml", ".yaml":
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var rows []map[string]interface{}
+		err = yaml.Unmarshal(body, &rows)
+		return rows, err
+	case ".json":
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return decodeJSON(body)
+	case ".csv":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		var srows []map[string]string
+		if err := csv2.NewReader(csv.NewReader(f)).LoadMaps(&srows); err != nil {
+			return nil, err
+		}
+		rows := make([]map[string]interface{}, len(srows))
+		for i, sr := range srows {
+			rows[i] = make(map[string]interface{}, len(sr))
+			for k, v := range sr {
+				rows[i][k] = v
+			}
+		}
+		return rows, nil
+	default:
+		return nil, errors.Errorf("Unsupported fixture file format: %s", ext)
+	}
+}
+
+// decodeJSON decodes JSON rows keeping integers intact.
+func decodeJSON(body []byte) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var rows []map[string]interface{}
+	if err := dec.Decode(&rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for k, v := range row {
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					row[k] = i
+				} else if f, err := n.Float64(); err == nil {
+					row[k] = f
+				}
+			}
+		}
+	}
+	return rows, nil
+}
+
+func insertQuery(table string, row map[string]interface{}) string {
+	cols := make([]string, 0, len(row))
+	for col := range row {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return "INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES (@" +
+		strings.Join(cols, ", @") + ")"
+}
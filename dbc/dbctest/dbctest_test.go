@@ -0,0 +1,124 @@
+package dbctest
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/context2"
+	"github.com/localhots/gobelt/dbc"
+	"github.com/localhots/gobelt/log"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type record struct {
+	ID   uint   `db:"id"`
+	Name string `db:"name"`
+}
+
+var conn dbc.Conn
+
+const sqliteMemoryDSN = "file:dbctest_test?mode=memory&cache=shared"
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	flv := flag.String("flavor", string(dbc.SQLite), "Database flavor")
+	dsn := flag.String("dsn", sqliteMemoryDSN, "Database source name")
+	flag.Parse()
+	if *dsn == "" {
+		log.Warn(ctx, "Database source name is not provided, some tests would be skipped")
+	} else {
+		var err error
+		conn, err = dbc.Connect(ctx, dbc.Flavor(*flv), *dsn)
+		if err != nil {
+			log.Fatalf(ctx, "Failed to connect: %v\n", err)
+		}
+		mustExecMain(conn.Exec(ctx, `DROP TABLE IF EXISTS dbctest`))
+		mustExecMain(conn.Exec(ctx, `
+			CREATE TABLE dbctest (
+				id INTEGER NOT NULL,
+				name VARCHAR(10) NOT NULL,
+				PRIMARY KEY (id)
+			)
+		`))
+	}
+
+	exitCode := m.Run()
+	if conn != nil {
+		if err := conn.Close(); err != nil {
+			log.Errorf(ctx, "Failed to close connection: %v\n", err)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func TestBegin(t *testing.T) {
+	requireConn(t)
+	for _, path := range []string{
+		"testdata/yaml/dbctest.yml",
+		"testdata/json/dbctest.json",
+		"testdata/csv/dbctest.csv",
+	} {
+		t.Run(path, func(t *testing.T) {
+			ctx, tx := Begin(t, conn, path)
+			var out []record
+			mustQuery(t, tx.Query(ctx, "SELECT id, name FROM dbctest ORDER BY id").Load(&out))
+			exp := []record{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}}
+			if !cmp.Equal(exp, out) {
+				t.Errorf("Records don't match: %s", cmp.Diff(exp, out))
+			}
+		})
+	}
+
+	// All transactions must be rolled back by now.
+	var n int
+	mustQuery(t, conn.Query(context2.TestContext(t), "SELECT COUNT(*) FROM dbctest").Load(&n))
+	if n != 0 {
+		t.Errorf("Expected table to be empty, got %d rows", n)
+	}
+}
+
+func TestInsertQuery(t *testing.T) {
+	const exp = "INSERT INTO tbl (id, name) VALUES (@id, @name)"
+	out := insertQuery("tbl", map[string]interface{}{"name": "Bob", "id": 1})
+	if out != exp {
+		t.Errorf("Expected query to be\n%s\ngot\n%s", exp, out)
+	}
+}
+
+func TestReadFixture(t *testing.T) {
+	out, err := readFixture("testdata/json/dbctest.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	exp := []map[string]interface{}{
+		{"id": int64(1), "name": "Alice"},
+		{"id": int64(2), "name": "Bob"},
+	}
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Rows don't match: %s", cmp.Diff(exp, out))
+	}
+}
+
+func mustExecMain(r dbc.ExecResult) {
+	if r.Error() != nil {
+		log.Fatalf(context.Background(), "Query failed: %v\n", r.Error())
+	}
+}
+
+func mustQuery(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+}
+
+func requireConn(t *testing.T) {
+	t.Helper()
+	if conn == nil {
+		t.Skip("Connection required")
+	}
+}
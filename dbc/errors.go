@@ -0,0 +1,183 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/juju/errors"
+	"github.com/lib/pq"
+)
+
+// DBError describes a database error returned by MySQL or PostgreSQL. Exec and
+// Query return driver errors as is, use AsDBError to get their description.
+type DBError struct {
+	// Code is the SQLSTATE code for PostgreSQL and the error number for
+	// MySQL.
+	Code string
+	// Constraint is the name of the violated constraint, if known.
+	Constraint string
+	// Table is the name of the table, if known.
+	Table   string
+	Message string
+	// Err is the original driver error.
+	Err error
+
+	kind errorKind
+}
+
+type errorKind byte
+
+const (
+	errorOther errorKind = iota
+	errorUniqueViolation
+	errorForeignKeyViolation
+	errorNotNullViolation
+	errorDeadlock
+	errorConnection
+)
+
+var (
+	_ error = &DBError{}
+
+	// Duplicate entry 'a@b.c' for key 'users.email'
+	mysqlDuplicateRegexp = regexp.MustCompile("for key '(?:([^'.]+)\\.)?([^']+)'")
+	// Cannot add or update a child row: a foreign key constraint fails
+	// (`db`.`posts`, CONSTRAINT `posts_user_id_fk` FOREIGN KEY ...
+	mysqlForeignKeyRegexp = regexp.MustCompile("\\(`[^`]+`\\.`([^`]+)`, CONSTRAINT `([^`]+)`")
+)
+
+func (e *DBError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original driver error.
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// AsDBError returns a DBError if the error or its cause is a MySQL or
+// PostgreSQL error.
+func AsDBError(err error) (*DBError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	switch terr := errors.Cause(err).(type) {
+	case *DBError:
+		return terr, true
+	case *pq.Error:
+		return newPostgreSQLError(terr), true
+	case *mysql.MySQLError:
+		return newMySQLError(terr), true
+	default:
+		return nil, false
+	}
+}
+
+// IsUniqueViolation tells if the error is caused by a unique or primary key
+// constraint violation.
+func IsUniqueViolation(err error) bool {
+	return errorKindOf(err) == errorUniqueViolation
+}
+
+// IsForeignKeyViolation tells if the error is caused by a foreign key
+// constraint violation.
+func IsForeignKeyViolation(err error) bool {
+	return errorKindOf(err) == errorForeignKeyViolation
+}
+
+// IsNotNullViolation tells if the error is caused by a NULL value in a column
+// that is not nullable.
+func IsNotNullViolation(err error) bool {
+	return errorKindOf(err) == errorNotNullViolation
+}
+
+// IsDeadlock tells if a transaction was rolled back because of a deadlock. It
+// is safe to retry such transactions.
+func IsDeadlock(err error) bool {
+	return errorKindOf(err) == errorDeadlock
+}
+
+// IsConnectionError tells if the error is caused by a broken or unavailable
+// connection, or by a timeout. Canceled requests are not connection errors.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch cause := errors.Cause(err); cause {
+	case driver.ErrBadConn, sql.ErrConnDone, mysql.ErrInvalidConn, context.DeadlineExceeded:
+		return true
+	default:
+		if _, ok := cause.(net.Error); ok {
+			return true
+		}
+	}
+	return errorKindOf(err) == errorConnection
+}
+
+func errorKindOf(err error) errorKind {
+	if dbErr, ok := AsDBError(err); ok {
+		return dbErr.kind
+	}
+	return errorOther
+}
+
+func newPostgreSQLError(err *pq.Error) *DBError {
+	e := &DBError{
+		Code:       string(err.Code),
+		Constraint: err.Constraint,
+		Table:      err.Table,
+		Message:    err.Message,
+		Err:        err,
+	}
+	switch e.Code {
+	case "23505":
+		e.kind = errorUniqueViolation
+	case "23503":
+		e.kind = errorForeignKeyViolation
+	case "23502":
+		e.kind = errorNotNullViolation
+	case "40P01":
+		e.kind = errorDeadlock
+	case "57P01", "57P02", "57P03":
+		// Admin shutdown, crash shutdown, cannot connect now
+		e.kind = errorConnection
+	default:
+		if err.Code.Class() == "08" {
+			e.kind = errorConnection
+		}
+	}
+	return e
+}
+
+func newMySQLError(err *mysql.MySQLError) *DBError {
+	e := &DBError{
+		Code:    strconv.Itoa(int(err.Number)),
+		Message: err.Message,
+		Err:     err,
+	}
+	switch err.Number {
+	case 1062, 1586:
+		e.kind = errorUniqueViolation
+		if m := mysqlDuplicateRegexp.FindStringSubmatch(err.Message); m != nil {
+			e.Table, e.Constraint = m[1], m[2]
+		}
+	case 1216, 1217, 1451, 1452:
+		e.kind = errorForeignKeyViolation
+		if m := mysqlForeignKeyRegexp.FindStringSubmatch(err.Message); m != nil {
+			e.Table, e.Constraint = m[1], m[2]
+		}
+	case 1048, 1364:
+		e.kind = errorNotNullViolation
+	case 1213:
+		e.kind = errorDeadlock
+	case 1040, 1053:
+		// Too many connections, server shutdown in progress
+		e.kind = errorConnection
+	}
+	return e
+}
@@ -0,0 +1,95 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/juju/errors"
+	"github.com/lib/pq"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		err  error
+		is   func(error) bool
+		name string
+	}{
+		{&pq.Error{Code: "23505"}, IsUniqueViolation, "unique"},
+		{&pq.Error{Code: "23503"}, IsForeignKeyViolation, "foreign key"},
+		{&pq.Error{Code: "23502"}, IsNotNullViolation, "not null"},
+		{&pq.Error{Code: "40P01"}, IsDeadlock, "deadlock"},
+		{&pq.Error{Code: "08006"}, IsConnectionError, "connection"},
+		{&mysql.MySQLError{Number: 1062}, IsUniqueViolation, "unique"},
+		{&mysql.MySQLError{Number: 1452}, IsForeignKeyViolation, "foreign key"},
+		{&mysql.MySQLError{Number: 1048}, IsNotNullViolation, "not null"},
+		{&mysql.MySQLError{Number: 1213}, IsDeadlock, "deadlock"},
+		{driver.ErrBadConn, IsConnectionError, "connection"},
+		{context.DeadlineExceeded, IsConnectionError, "connection"},
+		{errors.Annotate(&mysql.MySQLError{Number: 1062}, "Insert failed"), IsUniqueViolation, "unique"},
+	}
+	for _, test := range tests {
+		if !test.is(test.err) {
+			t.Errorf("Expected %v to be a %s error", test.err, test.name)
+		}
+	}
+
+	for _, err := range []error{nil, errors.New("boom"), context.Canceled, &pq.Error{Code: "42601"}} {
+		if IsUniqueViolation(err) || IsConnectionError(err) || IsDeadlock(err) {
+			t.Errorf("Expected %v not to be classified", err)
+		}
+	}
+}
+
+func TestDBErrorDetails(t *testing.T) {
+	dbErr, ok := AsDBError(&mysql.MySQLError{
+		Number:  1062,
+		Message: "Duplicate entry 'a@b.c' for key 'users.email'",
+	})
+	if !ok {
+		t.Fatal("Expected MySQL error to be recognized")
+	}
+	if dbErr.Code != "1062" || dbErr.Table != "users" || dbErr.Constraint != "email" {
+		t.Errorf("Unexpected error details: %+v", dbErr)
+	}
+
+	dbErr, _ = AsDBError(&mysql.MySQLError{
+		Number: 1452,
+		Message: "Cannot add or update a child row: a foreign key constraint fails " +
+			"(`db`.`posts`, CONSTRAINT `posts_user_id_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))",
+	})
+	if dbErr.Table != "posts" || dbErr.Constraint != "posts_user_id_fk" {
+		t.Errorf("Unexpected error details: %+v", dbErr)
+	}
+
+	dbErr, _ = AsDBError(&pq.Error{Code: "23505", Table: "users", Constraint: "users_email_key"})
+	if dbErr.Table != "users" || dbErr.Constraint != "users_email_key" {
+		t.Errorf("Unexpected error details: %+v", dbErr)
+	}
+}
+
+type failingDB struct {
+	err error
+}
+
+func (db failingDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, db.err
+}
+
+func (db failingDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, db.err
+}
+
+func TestDriverErrorsReturnedAsIs(t *testing.T) {
+	pqErr := &pq.Error{Code: "23505"}
+	c := &caller{db: failingDB{pqErr}, cb: &callbacks{}}
+	ctx := context.Background()
+	if err := c.Exec(ctx, "INSERT INTO t VALUES (1)").Error(); err != pqErr {
+		t.Errorf("Expected Exec to return driver error, got %#v", err)
+	}
+	if err := c.Query(ctx, "SELECT 1").Error(); err != pqErr {
+		t.Errorf("Expected Query to return driver error, got %#v", err)
+	}
+}
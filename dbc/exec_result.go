@@ -15,7 +15,7 @@ type ExecResult interface {
 }
 
 type execResult struct {
-	db  executer
+	db  Executer
 	err error
 	res sql.Result
 }
@@ -63,11 +63,11 @@ func (r *execResult) Then() ExecChain {
 
 // ExecChain ...
 type ExecChain interface {
-	executer
+	Executer
 }
 
 type execChain struct {
-	executer
+	Executer
 }
 
 type brokenChain struct {
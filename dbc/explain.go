@@ -0,0 +1,155 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/localhots/gobelt/log"
+)
+
+const (
+	explainTimeout = 5 * time.Second
+	// maxConcurrentExplains limits the number of plans captured at the same
+	// time. Slow queries that finish while the limit is reached are not
+	// explained.
+	maxConcurrentExplains = 2
+)
+
+// explainableStatements are the kinds of statements that EXPLAIN supports in
+// all flavors.
+var explainableStatements = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "WITH"}
+
+// WithExplainSlow enables capturing execution plans of queries that took
+// longer than the threshold. Plans are captured in the background with EXPLAIN
+// using a separate connection, so queries are not delayed. Once a plan is
+// captured fn is called with a copy of the query event that has Plan set and
+// with the query context, which could be done by then. If fn is nil plans are
+// logged with QueryLogger.Plan.
+func WithExplainSlow(threshold time.Duration, fn QueryHook) Option {
+	return func(c *dbWrapper) {
+		c.explainSlow(threshold, fn)
+	}
+}
+
+func (c *dbWrapper) explainSlow(threshold time.Duration, fn QueryHook) {
+	if fn == nil {
+		fn = (&QueryLogger{}).Plan
+	}
+	sem := make(chan struct{}, maxConcurrentExplains)
+	c.cb.addAfter(func(ctx context.Context, e *QueryEvent) {
+		if e.Took <= threshold {
+			return
+		}
+		if _, ok := explainQuery(c.flavor, e.Query); !ok {
+			return
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			return
+		}
+		if !c.startExplain() {
+			<-sem
+			return
+		}
+		// Hooks must not retain the event, arguments could be reused as well.
+		ev := *e
+		ev.Args = append([]interface{}(nil), e.Args...)
+		go func() {
+			defer c.explains.Done()
+			defer func() { <-sem }()
+			plan, err := explain(c.conn, c.flavor, &ev)
+			if err != nil {
+				log.Warn(ctx, "Failed to explain slow query", log.F{
+					"query": normalizeQuery(ev.Query),
+					"error": err,
+				})
+				return
+			}
+			ev.Plan = plan
+			fn(ctx, &ev)
+		}()
+	})
+}
+
+// startExplain registers a plan capture that Close waits for. It returns false
+// if the connection is being closed and the plan must not be captured.
+func (c *dbWrapper) startExplain() bool {
+	c.emu.Lock()
+	defer c.emu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.explains.Add(1)
+	return true
+}
+
+// explain runs EXPLAIN for a query with the same arguments. It uses a
+// connection from the pool because the original query could have been made
+// inside of a transaction or on a connection that is busy. Queries that can't
+// be explained have empty plans.
+func explain(db *sql.DB, f Flavor, e *QueryEvent) (string, error) {
+	query, ok := explainQuery(f, e.Query)
+	if !ok {
+		return "", nil
+	}
+	// Query context could be done already.
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, e.Args...)
+	if err != nil {
+		return "", errors.Annotate(err, "EXPLAIN failed")
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	// SQLite returns a row for each plan step with description in the last
+	// column, other flavors return a single JSON document.
+	var lines []string
+	vals := make([]interface{}, len(cols))
+	for i := range vals {
+		vals[i] = new(sql.NullString)
+	}
+	for rows.Next() {
+		if err := rows.Scan(vals...); err != nil {
+			return "", err
+		}
+		lines = append(lines, vals[len(vals)-1].(*sql.NullString).String)
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+// explainQuery returns an EXPLAIN statement for a query. It returns false if
+// the query can't be explained.
+func explainQuery(f Flavor, query string) (string, bool) {
+	fields := strings.Fields(strings.TrimLeft(query, "("))
+	if len(fields) == 0 {
+		return "", false
+	}
+	var ok bool
+	for _, stmt := range explainableStatements {
+		if strings.EqualFold(fields[0], stmt) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return "", false
+	}
+
+	switch f {
+	case PostgreSQL:
+		return "EXPLAIN (FORMAT JSON) " + query, true
+	case MySQL:
+		return "EXPLAIN FORMAT=JSON " + query, true
+	default:
+		return "EXPLAIN QUERY PLAN " + query, true
+	}
+}
@@ -0,0 +1,133 @@
+package dbc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestExplainQuery(t *testing.T) {
+	tests := []struct {
+		flavor Flavor
+		query  string
+		exp    string
+	}{
+		{PostgreSQL, "SELECT * FROM t WHERE id = $1", "EXPLAIN (FORMAT JSON) SELECT * FROM t WHERE id = $1"},
+		{MySQL, "\n\tupdate t SET a = ?", "EXPLAIN FORMAT=JSON \n\tupdate t SET a = ?"},
+		{SQLite, "(SELECT 1) UNION (SELECT 2)", "EXPLAIN QUERY PLAN (SELECT 1) UNION (SELECT 2)"},
+		{MySQL, "CREATE TABLE t (id int)", ""},
+		{MySQL, "", ""},
+	}
+	for _, test := range tests {
+		out, ok := explainQuery(test.flavor, test.query)
+		if out != test.exp || ok != (test.exp != "") {
+			t.Errorf("Expected %s query %q to be explained as %q, got %q", test.flavor, test.query, test.exp, out)
+		}
+	}
+}
+
+func TestExplainSlow(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	plans := make(chan *QueryEvent, 1)
+	econn, err := Connect(ctx, flavor, testDSN, WithExplainSlow(0, func(_ context.Context, e *QueryEvent) {
+		plans <- e
+	}))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer econn.Close()
+
+	var name string
+	var afterPlan string
+	econn.AfterQuery(func(_ context.Context, e *QueryEvent) { afterPlan = e.Plan })
+	mustQuery(t, econn.Query(ctx, "SELECT name FROM sqldb_test WHERE id = "+flavor.placeholder(1), 1).Load(&name))
+	if afterPlan != "" {
+		t.Error("Expected plan to be captured in the background")
+	}
+	select {
+	case e := <-plans:
+		if e.Plan == "" {
+			t.Error("Expected query plan to be captured")
+		}
+		if len(e.Args) != 1 {
+			t.Errorf("Expected query arguments to be preserved, got %v", e.Args)
+		}
+	case <-time.After(explainTimeout):
+		t.Fatal("Plan handler was not called")
+	}
+}
+
+func TestExplainSlowConcurrencyLimit(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	started := make(chan struct{}, maxConcurrentExplains+1)
+	release := make(chan struct{})
+	econn, err := Connect(ctx, flavor, testDSN, WithExplainSlow(0, func(context.Context, *QueryEvent) {
+		started <- struct{}{}
+		<-release
+	}))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// Queries must not wait for blocked plan handlers.
+	for i := 0; i < maxConcurrentExplains+1; i++ {
+		var name string
+		mustQuery(t, econn.Query(ctx, "SELECT name FROM sqldb_test WHERE id = "+flavor.placeholder(1), 1).Load(&name))
+	}
+	for i := 0; i < maxConcurrentExplains; i++ {
+		select {
+		case <-started:
+		case <-time.After(explainTimeout):
+			t.Fatal("Plan handler was not called")
+		}
+	}
+	select {
+	case <-started:
+		t.Error("Expected the number of concurrent explains to be limited")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := econn.Close(); err != nil {
+		t.Errorf("Failed to close connection: %v", err)
+	}
+}
+
+func TestExplainSlowDuringClose(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	econn, err := Connect(ctx, flavor, testDSN, WithExplainSlow(0, func(context.Context, *QueryEvent) {}))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	c := econn.(*dbWrapper)
+
+	// Slow queries could finish while the connection is being closed.
+	started := make(chan struct{})
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		close(started)
+		for {
+			c.cb.callAfter(ctx, &QueryEvent{Query: "SELECT 1", Took: time.Second})
+			select {
+			case <-closed:
+				return
+			default:
+			}
+		}
+	}()
+	<-started
+	if err := c.Close(); err != nil {
+		t.Errorf("Failed to close connection: %v", err)
+	}
+	close(closed)
+	<-done
+	if c.startExplain() {
+		t.Error("Expected plans not to be captured after close")
+	}
+}
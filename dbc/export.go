@@ -0,0 +1,206 @@
+package dbc
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteCSV writes rows to w in CSV format. Column names are written in the
+// first row. NULL values are written as empty strings, binary values are
+// base64 encoded.
+func (r *rows) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := r.export(func(cols []exportColumn, vals []interface{}) error {
+		if vals == nil {
+			names := make([]string, len(cols))
+			for i, col := range cols {
+				names[i] = col.name
+			}
+			return cw.Write(names)
+		}
+		rec := make([]string, len(vals))
+		for i, v := range vals {
+			rec[i] = formatCSVValue(v)
+		}
+		return cw.Write(rec)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes rows to w as JSON objects separated by newlines. Keys
+// follow the order of columns. Binary values are base64 encoded, JSON documents
+// of MySQL and PostgreSQL JSON columns are embedded as is. Floating point NaN
+// and infinity values are written as strings.
+func (r *rows) WriteJSONLines(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var keys [][]byte
+	err := r.export(func(cols []exportColumn, vals []interface{}) error {
+		if vals == nil {
+			keys = make([][]byte, len(cols))
+			for i, col := range cols {
+				keys[i], _ = json.Marshal(col.name)
+			}
+			return nil
+		}
+		bw.WriteByte('{')
+		for i, v := range vals {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(keys[i])
+			bw.WriteByte(':')
+			b, err := marshalJSONValue(v, cols[i])
+			if err != nil {
+				return err
+			}
+			bw.Write(b)
+		}
+		_, err := bw.WriteString("}\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// export calls fn with columns and nil values first, and then with values of
+// each row. Values are normalized according to column types.
+func (r *rows) export(fn func(cols []exportColumn, vals []interface{}) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	cols, err := r.rows.Columns()
+	if err != nil {
+		return err
+	}
+	colTypes, err := r.rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	ecols := make([]exportColumn, len(cols))
+	for i, ct := range colTypes {
+		ecols[i] = newExportColumn(r.flavor, cols[i], ct.DatabaseTypeName())
+	}
+	if err := fn(ecols, nil); err != nil {
+		return err
+	}
+
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for r.rows.Next() {
+		if err := r.rows.Scan(ptrs...); err != nil {
+			return r.readFailed(err)
+		}
+		for i, v := range vals {
+			vals[i] = normalizeValue(v, ecols[i].cat)
+		}
+		if err := fn(ecols, vals); err != nil {
+			return err
+		}
+	}
+	return r.readFailed(r.rows.Err())
+}
+
+// exportColumn describes how values of a column are exported.
+type exportColumn struct {
+	name string
+	cat  typeCategory
+	// json is true for columns that hold JSON documents. Such values are
+	// embedded into JSON lines as is instead of being encoded as strings.
+	json bool
+}
+
+// newExportColumn returns an export description of a column of the given
+// database type. Flavors report types and return values differently:
+//   - MySQL returns all values of text protocol queries as bytes, including
+//     BIT values, which are binary, and JSON documents;
+//   - PostgreSQL returns NUMERIC and JSON/JSONB values as text bytes and
+//     BYTEA values as decoded bytes;
+//   - SQLite has no boolean storage class and returns BOOLEAN values as
+//     integers, JSON is stored as plain text and is not recognized.
+func newExportColumn(f Flavor, name, dbType string) exportColumn {
+	col := exportColumn{name: name, cat: columnTypeCategory(dbType)}
+	typ := strings.ToLower(dbType)
+	switch f {
+	case MySQL:
+		col.json = typ == "json"
+		if typ == "bit" {
+			col.cat = categoryBytes
+		}
+	case PostgreSQL:
+		col.json = typ == "json" || typ == "jsonb"
+	}
+	return col
+}
+
+// marshalJSONValue returns the JSON encoding of a normalized value. Numeric
+// values that drivers return as text, e.g. decimals, are written as numbers
+// unless they are NaN or infinity, which are not valid JSON numbers.
+func marshalJSONValue(v interface{}, col exportColumn) ([]byte, error) {
+	switch tv := v.(type) {
+	case string:
+		switch {
+		case col.json && json.Valid([]byte(tv)):
+			return json.Marshal(json.RawMessage(tv))
+		case col.cat == categoryInt, col.cat == categoryFloat, col.cat == categoryDecimal:
+			if b := []byte(tv); isJSONNumber(b) {
+				return b, nil
+			}
+		}
+	case float64:
+		if math.IsNaN(tv) || math.IsInf(tv, 0) {
+			return json.Marshal(strconv.FormatFloat(tv, 'g', -1, 64))
+		}
+	}
+	return json.Marshal(v)
+}
+
+// isJSONNumber returns true if b is a valid JSON number literal.
+func isJSONNumber(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	if c := b[0]; c != '-' && (c < '0' || c > '9') {
+		return false
+	}
+	return json.Valid(b)
+}
+
+func formatCSVValue(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case []byte:
+		return base64.StdEncoding.EncodeToString(tv)
+	case int64:
+		return strconv.FormatInt(tv, 10)
+	case float64:
+		return strconv.FormatFloat(tv, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(tv)
+	case time.Time:
+		return tv.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(tv)
+	}
+}
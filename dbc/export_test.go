@@ -0,0 +1,143 @@
+package dbc
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func exportTestRows(t *testing.T) Rows {
+	t.Helper()
+	res := &CachedResult{
+		Columns:     []string{"id", "name", "score", "data", "created_at"},
+		ColumnTypes: []string{"BIGINT", "VARCHAR", "DECIMAL", "BLOB", "DATETIME"},
+		Rows: [][]interface{}{
+			{int64(1), []byte("Alice, \"A\""), []byte("1.50"), []byte{0, 1}, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{int64(2), nil, nil, nil, nil},
+		},
+	}
+	rr, err := replayDB.QueryContext(context.Background(), "", res)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	return &rows{rows: rr, flavor: MySQL}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportTestRows(t).WriteCSV(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	const exp = "id,name,score,data,created_at\n" +
+		"1,\"Alice, \"\"A\"\"\",1.50,AAE=,2020-01-02T03:04:05Z\n" +
+		"2,,,,\n"
+	if buf.String() != exp {
+		t.Errorf("Expected CSV to be\n%s\ngot\n%s", exp, buf.String())
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportTestRows(t).WriteJSONLines(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	const exp = `{"id":1,"name":"Alice, \"A\"","score":1.50,"data":"AAE=","created_at":"2020-01-02T03:04:05Z"}` + "\n" +
+		`{"id":2,"name":null,"score":null,"data":null,"created_at":null}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("Expected JSON lines to be\n%s\ngot\n%s", exp, buf.String())
+	}
+}
+
+func TestWriteJSONLinesFlavors(t *testing.T) {
+	cases := []struct {
+		name   string
+		flavor Flavor
+		types  []string
+		row    []interface{}
+		exp    string
+	}{
+		{
+			name:   "PostgreSQL",
+			flavor: PostgreSQL,
+			types:  []string{"NUMERIC", "JSONB", "BYTEA", "BOOL"},
+			row:    []interface{}{[]byte("NaN"), []byte(`{"a": [1, 2]}`), []byte{0xff}, true},
+			exp:    `{"a":"NaN","b":{"a":[1,2]},"c":"/w==","d":true}`,
+		},
+		{
+			name:   "MySQL",
+			flavor: MySQL,
+			types:  []string{"DECIMAL", "JSON", "BIT", "TINYINT"},
+			row:    []interface{}{[]byte("-1.5"), []byte(`[1]`), []byte{1}, []byte("1")},
+			exp:    `{"a":-1.5,"b":[1],"c":"AQ==","d":1}`,
+		},
+		{
+			name:   "SQLite",
+			flavor: SQLite,
+			types:  []string{"REAL", "JSON", "BLOB", "BOOLEAN"},
+			row:    []interface{}{float64(1.5), `{"a":1}`, []byte{1}, int64(1)},
+			exp:    `{"a":1.5,"b":"{\"a\":1}","c":"AQ==","d":true}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := &CachedResult{
+				Columns:     []string{"a", "b", "c", "d"},
+				ColumnTypes: c.types,
+				Rows:        [][]interface{}{c.row},
+			}
+			rr, err := replayDB.QueryContext(context.Background(), "", res)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := (&rows{rows: rr, flavor: c.flavor}).WriteJSONLines(&buf); err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if exp := c.exp + "\n"; buf.String() != exp {
+				t.Errorf("Expected JSON lines to be\n%s\ngot\n%s", exp, buf.String())
+			}
+		})
+	}
+}
+
+func TestWriteJSONLinesNonFiniteFloats(t *testing.T) {
+	res := &CachedResult{
+		Columns:     []string{"a", "b", "c"},
+		ColumnTypes: []string{"REAL", "REAL", "FLOAT8"},
+		Rows:        [][]interface{}{{math.NaN(), math.Inf(-1), []byte("Infinity")}},
+	}
+	rr, err := replayDB.QueryContext(context.Background(), "", res)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := (&rows{rows: rr, flavor: PostgreSQL}).WriteJSONLines(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	const exp = `{"a":"NaN","b":"-Inf","c":"+Inf"}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("Expected JSON lines to be\n%s\ngot\n%s", exp, buf.String())
+	}
+}
+
+func TestWriteCSVJSONColumn(t *testing.T) {
+	res := &CachedResult{
+		Columns:     []string{"doc"},
+		ColumnTypes: []string{"JSONB"},
+		Rows:        [][]interface{}{{[]byte(`{"a":1}`)}},
+	}
+	rr, err := replayDB.QueryContext(context.Background(), "", res)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := (&rows{rows: rr, flavor: PostgreSQL}).WriteCSV(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	const exp = "doc\n\"{\"\"a\"\":1}\"\n"
+	if buf.String() != exp {
+		t.Errorf("Expected CSV to be\n%s\ngot\n%s", exp, buf.String())
+	}
+}
@@ -0,0 +1,142 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/localhots/gobelt/log"
+)
+
+// ErrLockNotAcquired is returned when a named lock is held by another session
+// and could not be acquired in time.
+var ErrLockNotAcquired = errors.New("Lock is held by another session")
+
+const lockReleaseTimeout = 5 * time.Second
+
+func (c *dbWrapper) WithLock(ctx context.Context, name string, fn func() error) error {
+	return c.withLock(ctx, name, false, fn)
+}
+
+func (c *dbWrapper) TryWithLock(ctx context.Context, name string, fn func() error) error {
+	return c.withLock(ctx, name, true, fn)
+}
+
+// withLock acquires a named lock on a connection that is taken out of the
+// pool, because locks are bound to a session and must be released by the same
+// session that acquired them. For the same reason an ambient transaction is
+// not used.
+func (c *dbWrapper) withLock(ctx context.Context, name string, try bool, fn func() error) error {
+	if c.flavor != MySQL && c.flavor != PostgreSQL {
+		return errors.Errorf("Named locks are not supported by %s flavor", c.flavor)
+	}
+
+	sc, err := c.conn.Conn(ctx)
+	if err != nil {
+		return errors.Annotate(err, "Failed to obtain a connection")
+	}
+	pinned := &caller{
+		db:      sc,
+		cb:      c.cb,
+		flavor:  c.flavor,
+		tags:    c.tags,
+		breaker: c.breaker,
+	}
+
+	ok, err := acquireLock(ctx, pinned, name, try)
+	if err != nil || !ok {
+		sc.Close()
+		if err != nil {
+			return errors.Annotatef(err, "Failed to acquire lock %s", name)
+		}
+		return ErrLockNotAcquired
+	}
+
+	defer func() {
+		// Lock must be released even if the context is already done.
+		rctx, cancel := context.WithTimeout(context.Background(), lockReleaseTimeout)
+		defer cancel()
+		if err := releaseLock(rctx, pinned, name); err != nil {
+			log.Error(ctx, "Failed to release lock", log.F{
+				"lock":  name,
+				"error": err,
+			})
+			// Connection that still holds the lock must not be returned to
+			// the pool, closing it makes the database release the lock.
+			sc.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		sc.Close()
+	}()
+
+	return fn()
+}
+
+func acquireLock(ctx context.Context, c *caller, name string, try bool) (bool, error) {
+	switch c.flavor {
+	case MySQL:
+		var res sql.NullInt64
+		err := c.Query(ctx, "SELECT GET_LOCK(?, ?)", name, mysqlLockTimeout(ctx, try)).Load(&res)
+		if err != nil {
+			return false, err
+		}
+		if !res.Valid {
+			return false, errors.New("GET_LOCK returned NULL")
+		}
+		return res.Int64 == 1, nil
+	default:
+		if try {
+			var ok bool
+			err := c.Query(ctx, "SELECT pg_try_advisory_lock($1)", advisoryLockKey(name)).Load(&ok)
+			return ok, err
+		}
+		err := c.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey(name)).Error()
+		return err == nil, err
+	}
+}
+
+func releaseLock(ctx context.Context, c *caller, name string) error {
+	var query string
+	var arg interface{}
+	switch c.flavor {
+	case MySQL:
+		query, arg = "SELECT RELEASE_LOCK(?)", name
+	default:
+		query, arg = "SELECT pg_advisory_unlock($1)", advisoryLockKey(name)
+	}
+	var released sql.NullBool
+	if err := c.Query(ctx, query, arg).Load(&released); err != nil {
+		return err
+	}
+	if !released.Bool {
+		return errors.New("Lock was not held by this session")
+	}
+	return nil
+}
+
+// mysqlLockTimeout converts context deadline into GET_LOCK timeout in
+// seconds. Negative timeout means infinite waiting.
+func mysqlLockTimeout(ctx context.Context, try bool) int {
+	if try {
+		return 0
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return -1
+	}
+	sec := math.Ceil(time.Until(deadline).Seconds())
+	if sec < 0 {
+		return 0
+	}
+	return int(sec)
+}
+
+// advisoryLockKey converts a lock name into a PostgreSQL advisory lock key.
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
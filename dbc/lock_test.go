@@ -0,0 +1,113 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestWithLock(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	if flavor != MySQL && flavor != PostgreSQL {
+		if err := conn.WithLock(ctx, "sqldb_test", func() error { return nil }); err == nil {
+			t.Error("Expected WithLock to fail")
+		}
+		return
+	}
+
+	var called bool
+	err := conn.WithLock(ctx, "sqldb_test", func() error {
+		called = true
+		err := conn.TryWithLock(ctx, "sqldb_test", func() error {
+			t.Error("Lock was acquired twice")
+			return nil
+		})
+		if err != ErrLockNotAcquired {
+			t.Errorf("Expected ErrLockNotAcquired, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if !called {
+		t.Error("Function was not called")
+	}
+
+	err = conn.TryWithLock(ctx, "sqldb_test", func() error { return nil })
+	if err != nil {
+		t.Errorf("Lock was not released: %v", err)
+	}
+}
+
+func TestAdvisoryLockKey(t *testing.T) {
+	if advisoryLockKey("foo") != advisoryLockKey("foo") {
+		t.Error("Lock key is not stable")
+	}
+	if advisoryLockKey("foo") == advisoryLockKey("bar") {
+		t.Error("Different names produced the same key")
+	}
+}
+
+// replayQueryer answers queries with predefined results.
+type replayQueryer map[string]*CachedResult
+
+func (q replayQueryer) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errors.New("Not supported")
+}
+
+func (q replayQueryer) QueryContext(ctx context.Context, query string, _ ...interface{}) (*sql.Rows, error) {
+	res, ok := q[query]
+	if !ok {
+		return nil, errors.New("Unexpected query: " + query)
+	}
+	return replayDB.QueryContext(ctx, "", res)
+}
+
+func TestLockResults(t *testing.T) {
+	ctx := context.Background()
+	one := func(v interface{}) *CachedResult {
+		return &CachedResult{
+			Columns:     []string{"res"},
+			ColumnTypes: []string{"BIGINT"},
+			Rows:        [][]interface{}{{v}},
+		}
+	}
+
+	mysql := &caller{flavor: MySQL, cb: &callbacks{}, db: replayQueryer{
+		"SELECT GET_LOCK(?, ?)":  one(int64(1)),
+		"SELECT RELEASE_LOCK(?)": one(int64(1)),
+	}}
+	if ok, err := acquireLock(ctx, mysql, "foo", false); err != nil || !ok {
+		t.Errorf("Expected lock to be acquired, got %v, %v", ok, err)
+	}
+	if err := releaseLock(ctx, mysql, "foo"); err != nil {
+		t.Errorf("Expected lock to be released, got %v", err)
+	}
+
+	mysql.db = replayQueryer{
+		"SELECT GET_LOCK(?, ?)":  one(int64(0)),
+		"SELECT RELEASE_LOCK(?)": one(nil),
+	}
+	if ok, err := acquireLock(ctx, mysql, "foo", true); err != nil || ok {
+		t.Errorf("Expected lock not to be acquired, got %v, %v", ok, err)
+	}
+	if err := releaseLock(ctx, mysql, "foo"); err == nil {
+		t.Error("Expected release of a lock that is not held to fail")
+	}
+
+	pg := &caller{flavor: PostgreSQL, cb: &callbacks{}, db: replayQueryer{
+		"SELECT pg_try_advisory_lock($1)": one(true),
+		"SELECT pg_advisory_unlock($1)":   one(true),
+	}}
+	if ok, err := acquireLock(ctx, pg, "foo", true); err != nil || !ok {
+		t.Errorf("Expected lock to be acquired, got %v, %v", ok, err)
+	}
+	if err := releaseLock(ctx, pg, "foo"); err != nil {
+		t.Errorf("Expected lock to be released, got %v", err)
+	}
+}
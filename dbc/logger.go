@@ -0,0 +1,117 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/localhots/gobelt/log"
+)
+
+// QueryLogger logs queries using the log package. Use its Before and After
+// methods as connection hooks:
+//
+//	ql := &dbc.QueryLogger{SlowThreshold: time.Second, Redact: []string{"password"}}
+//	conn.BeforeQuery(ql.Before)
+//	conn.AfterQuery(ql.After)
+//
+// Plans of slow queries are logged by its Plan method:
+//
+//	conn, err := dbc.Connect(ctx, dbc.MySQL, dsn, dbc.WithExplainSlow(time.Second, ql.Plan))
+type QueryLogger struct {
+	// Level is the logging level of regular queries. Failed queries are logged
+	// with error level.
+	Level log.Level
+	// SlowThreshold is the query duration after which queries are logged with
+	// warning level. Zero value disables slow query detection.
+	SlowThreshold time.Duration
+	// Redact is a list of named parameters whose values must not be logged.
+	// Names are case insensitive. Positional arguments can't be redacted.
+	Redact []string
+}
+
+const redactedValue = "[REDACTED]"
+
+// Before logs a query before it is executed with debug level.
+func (l *QueryLogger) Before(ctx context.Context, e *QueryEvent) {
+	log.Debug(ctx, "Executing query", l.fields(e))
+}
+
+// After logs an executed query with the level that depends on query duration
+// and result.
+func (l *QueryLogger) After(ctx context.Context, e *QueryEvent) {
+	f := l.fields(e)
+	f["took"] = e.Took
+	switch {
+	case e.Err != nil:
+		f["error"] = e.Err
+		log.Error(ctx, "Query failed", f)
+	case l.SlowThreshold > 0 && e.Took > l.SlowThreshold:
+		log.Warn(ctx, "Slow query", f)
+	default:
+		logWithLevel(ctx, l.Level, "Query executed", f)
+	}
+}
+
+// Plan logs an execution plan of a slow query with warning level. Use it as a
+// plan handler of WithExplainSlow.
+func (l *QueryLogger) Plan(ctx context.Context, e *QueryEvent) {
+	f := l.fields(e)
+	f["took"] = e.Took
+	f["plan"] = e.Plan
+	log.Warn(ctx, "Slow query plan", f)
+}
+
+func (l *QueryLogger) fields(e *QueryEvent) log.F {
+	f := log.F{"query": normalizeQuery(e.Query)}
+	if len(e.Args) == 0 {
+		return f
+	}
+	if len(e.ArgNames) != len(e.Args) {
+		f["args"] = e.Args
+		return f
+	}
+	args := make(map[string]interface{}, len(e.Args))
+	for i, name := range e.ArgNames {
+		if l.redacted(name) {
+			args[name] = redactedValue
+		} else {
+			args[name] = e.Args[i]
+			if na, ok := e.Args[i].(sql.NamedArg); ok {
+				args[name] = na.Value
+			}
+		}
+	}
+	f["args"] = args
+	return f
+}
+
+func (l *QueryLogger) redacted(name string) bool {
+	for _, r := range l.Redact {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeQuery collapses all whitespace in a query so that it fits in one
+// line. Whitespace inside string literals is collapsed as well.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func logWithLevel(ctx context.Context, lvl log.Level, msg string, f log.F) {
+	switch lvl {
+	case log.LevelDebug:
+		log.Debug(ctx, msg, f)
+	case log.LevelInfo:
+		log.Info(ctx, msg, f)
+	case log.LevelWarn:
+		log.Warn(ctx, msg, f)
+	default:
+		// Fatal level is not used for queries.
+		log.Error(ctx, msg, f)
+	}
+}
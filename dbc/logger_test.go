@@ -0,0 +1,49 @@
+package dbc
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/log"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	const exp = "SELECT id, name FROM tbl WHERE id = ?"
+	out := normalizeQuery(`
+		SELECT id, name
+		FROM tbl
+		WHERE id = ?
+	`)
+	if out != exp {
+		t.Errorf("Expected query to be\n%s\ngot\n%s", exp, out)
+	}
+}
+
+func TestQueryLoggerFields(t *testing.T) {
+	l := &QueryLogger{Redact: []string{"Password"}}
+
+	named := l.fields(&QueryEvent{
+		Query:    "UPDATE users SET password = ? WHERE id = ?",
+		Args:     []interface{}{"secret", 1},
+		ArgNames: []string{"password", "id"},
+	})
+	expNamed := log.F{
+		"query": "UPDATE users SET password = ? WHERE id = ?",
+		"args":  map[string]interface{}{"password": redactedValue, "id": 1},
+	}
+	if !cmp.Equal(expNamed, named) {
+		t.Errorf("Fields don't match: %s", cmp.Diff(expNamed, named))
+	}
+
+	positional := l.fields(&QueryEvent{
+		Query: "SELECT * FROM users WHERE id = ?",
+		Args:  []interface{}{1},
+	})
+	expPositional := log.F{
+		"query": "SELECT * FROM users WHERE id = ?",
+		"args":  []interface{}{1},
+	}
+	if !cmp.Equal(expPositional, positional) {
+		t.Errorf("Fields don't match: %s", cmp.Diff(expPositional, positional))
+	}
+}
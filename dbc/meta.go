@@ -0,0 +1,141 @@
+package dbc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// typeMeta holds metadata of a struct type derived from field tags. It is
+// built once per type and shared by Load, named parameters and struct-based
+// queries.
+type typeMeta struct {
+	// fields are top level tagged fields with tag options. fieldsErr is set
+	// if tag options are not valid for field types.
+	fields    []structField
+	fieldsErr error
+	// index maps column names to field index sequences, including fields of
+	// embedded structs.
+	index map[string][]int
+	// columns caches field associations for column sets returned by queries,
+	// up to maxColumnSets of them. ncolumns is the number of cached sets.
+	columns  sync.Map
+	ncolumns int32
+}
+
+// maxColumnSets limits the number of column sets cached for a struct type.
+// Queries with generated column lists could otherwise grow the cache without
+// bounds, associations of further sets are built on every call.
+const maxColumnSets = 64
+
+var typeMetaCache sync.Map
+
+// getTypeMeta returns metadata of a struct type.
+func getTypeMeta(typ reflect.Type) *typeMeta {
+	if m, ok := typeMetaCache.Load(typ); ok {
+		return m.(*typeMeta)
+	}
+	m := &typeMeta{index: buildFieldIndex(typ)}
+	m.fields, m.fieldsErr = parseStructFields(typ)
+	actual, _ := typeMetaCache.LoadOrStore(typ, m)
+	return actual.(*typeMeta)
+}
+
+// columnFields returns field index sequences for columns in the order of
+// columns. Columns that are not mapped to fields have nil indices.
+func (m *typeMeta) columnFields(cols []string) [][]int {
+	key := strings.Join(cols, "\x00")
+	if cf, ok := m.columns.Load(key); ok {
+		return cf.([][]int)
+	}
+	cf := make([][]int, len(cols))
+	for i, col := range cols {
+		cf[i] = m.index[col]
+	}
+	// The limit can be exceeded slightly by concurrent calls.
+	if atomic.LoadInt32(&m.ncolumns) < maxColumnSets {
+		if _, loaded := m.columns.LoadOrStore(key, cf); !loaded {
+			atomic.AddInt32(&m.ncolumns, 1)
+		}
+	}
+	return cf
+}
+
+// scanTargets fills targets with pointers to fields of a struct value that
+// columns are scanned into. Unmapped columns are discarded. Nil embedded
+// struct pointers are allocated.
+func scanTargets(val reflect.Value, cf [][]int, targets []interface{}) error {
+	for i, fi := range cf {
+		if fi == nil {
+			targets[i] = &nopScanner{}
+			continue
+		}
+		f, err := fieldByIndexAlloc(val, fi)
+		if err != nil {
+			return err
+		}
+		targets[i] = f.Addr().Interface()
+	}
+	return nil
+}
+
+// fieldByIndex returns a nested field like reflect.Value.FieldByIndex. It
+// returns false instead of panicking if the field belongs to a nil embedded
+// struct pointer.
+func fieldByIndex(val reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return reflect.Value{}, false
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val, true
+}
+
+// fieldByIndexAlloc returns a nested field of an addressable struct value,
+// nil embedded struct pointers on the way are allocated.
+func fieldByIndexAlloc(val reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				if !val.CanSet() {
+					return reflect.Value{}, fmt.Errorf("Can't allocate embedded pointer to unexported struct %s", val.Type().Elem())
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val, nil
+}
+
+// copyEmbedded replaces embedded struct pointers of a struct value with
+// pointers to copies, so that fields can be modified without affecting the
+// value it was copied from.
+func copyEmbedded(val reflect.Value) {
+	for i := 0; i < val.NumField(); i++ {
+		f := val.Type().Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		fv := val.Field(i)
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			copyEmbedded(fv)
+		case f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct:
+			if fv.IsNil() || !fv.CanSet() {
+				continue
+			}
+			cp := reflect.New(f.Type.Elem())
+			cp.Elem().Set(fv.Elem())
+			copyEmbedded(cp.Elem())
+			fv.Set(cp)
+		}
+	}
+}
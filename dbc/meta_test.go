@@ -0,0 +1,143 @@
+package dbc
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type metaBase struct {
+	ID int64 `db:"id"`
+}
+
+type metaUser struct {
+	metaBase
+	Name    string  `db:"name,pk"`
+	Email   *string `db:"email"`
+	Ignored string  `db:"-"`
+	NoTag   string
+}
+
+func TestTypeMetaColumnFields(t *testing.T) {
+	m := getTypeMeta(reflect.TypeOf(metaUser{}))
+	cf := m.columnFields([]string{"name", "unknown", "id", "email"})
+	exp := [][]int{{1}, nil, {0, 0}, {2}}
+	if !reflect.DeepEqual(exp, cf) {
+		t.Errorf("Expected column fields %v, got %v", exp, cf)
+	}
+	if len(m.fields) != 2 || m.fields[0].column != "name" || !m.fields[0].pk {
+		t.Errorf("Unexpected struct fields: %+v", m.fields)
+	}
+}
+
+func TestTypeMetaColumnSetsLimit(t *testing.T) {
+	m := getTypeMeta(reflect.TypeOf(struct {
+		Foo int `db:"foo"`
+	}{}))
+	for i := 0; i < maxColumnSets*2; i++ {
+		cf := m.columnFields([]string{"foo", strconv.Itoa(i)})
+		if len(cf) != 2 || cf[0] == nil || cf[1] != nil {
+			t.Fatalf("Unexpected column fields: %v", cf)
+		}
+	}
+	var n int
+	m.columns.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	if n != maxColumnSets {
+		t.Errorf("Expected %d cached column sets, got %d", maxColumnSets, n)
+	}
+}
+
+func TestTypeMetaConcurrent(t *testing.T) {
+	typ := reflect.TypeOf(struct {
+		Foo int `db:"foo"`
+	}{})
+	cols := []string{"foo"}
+
+	var wg sync.WaitGroup
+	metas := make([]*typeMeta, 10)
+	for i := range metas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			metas[i] = getTypeMeta(typ)
+			metas[i].columnFields(cols)
+		}(i)
+	}
+	wg.Wait()
+	for _, m := range metas[1:] {
+		if m != metas[0] {
+			t.Fatal("Expected metadata to be shared")
+		}
+	}
+}
+
+func TestLoadEmbeddedStruct(t *testing.T) {
+	res := &CachedResult{
+		Columns:     []string{"id", "name", "email", "extra"},
+		ColumnTypes: []string{"BIGINT", "VARCHAR", "VARCHAR", "VARCHAR"},
+		Rows: [][]interface{}{
+			{int64(1), "Alice", "alice@example.com", "x"},
+			{int64(2), "Bob", nil, "y"},
+		},
+	}
+	rr, err := replayDB.QueryContext(context.Background(), "", res)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var users []metaUser
+	if err := (&rows{rows: rr, flavor: MySQL}).Load(&users); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(users))
+	}
+	if users[0].ID != 1 || users[0].Name != "Alice" || users[0].Email == nil || *users[0].Email != "alice@example.com" {
+		t.Errorf("Unexpected first user: %+v", users[0])
+	}
+	if users[1].ID != 2 || users[1].Name != "Bob" || users[1].Email != nil {
+		t.Errorf("Unexpected second user: %+v", users[1])
+	}
+}
+
+func TestLoadEmbeddedPointer(t *testing.T) {
+	res := &CachedResult{
+		Columns:     []string{"id", "created_by"},
+		ColumnTypes: []string{"BIGINT", "VARCHAR"},
+		Rows:        [][]interface{}{{int64(1), "alice"}},
+	}
+	load := func(dest interface{}) error {
+		rr, err := replayDB.QueryContext(context.Background(), "", res)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return (&rows{rows: rr, flavor: MySQL}).Load(dest)
+	}
+
+	var recs []namedRecordExported
+	if err := load(&recs); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(recs) != 1 || recs[0].ID != 1 || recs[0].NamedAudit == nil || recs[0].CreatedBy != "alice" {
+		t.Errorf("Unexpected records: %+v", recs)
+	}
+
+	audit := &NamedAudit{CreatedBy: "bob"}
+	rec := namedRecordExported{NamedAudit: audit}
+	if err := load(&rec); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if rec.CreatedBy != "alice" || audit.CreatedBy != "bob" {
+		t.Errorf("Expected embedded struct to be copied, got %+v and %+v", rec.NamedAudit, audit)
+	}
+
+	// Pointers to unexported types can't be allocated.
+	var unexp namedRecord
+	if err := load(&unexp); err == nil {
+		t.Error("Expected nil embedded pointer to unexported struct to fail")
+	}
+}
@@ -1,6 +1,7 @@
 package dbc
 
 import (
+	"database/sql"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -13,40 +14,84 @@ var namedRegexp = regexp.MustCompile("" +
 	"`[^`]+`|" +
 	`'[^']+'|` +
 	`"[^"]+"|` +
-	`@[a-zA-Z][a-zA-Z0-9_]*`)
+	`@[a-zA-Z][a-zA-Z0-9_]*(?:\.[a-zA-Z][a-zA-Z0-9_]*)*`)
 
-func prepareNamedQuery(query string, p namedParams) (newQuery string, args []interface{}, err error) {
+// prepareNamedQuery replaces named parameters with positional placeholders.
+// Flavors with drivers that support named arguments natively get sql.NamedArg
+// arguments instead, one for each distinct name.
+func prepareNamedQuery(f Flavor, query string, p namedParams) (newQuery string, args []interface{}, names []string, err error) {
+	native := f.nativeNamedArgs()
+	// seen maps native parameter names to original names.
+	var seen map[string]string
+	if native {
+		seen = map[string]string{}
+	}
 	newQuery = namedRegexp.ReplaceAllStringFunc(query, func(m string) string {
 		if !strings.HasPrefix(m, "@") {
 			return m
 		}
-		val, ok := p.Get(m[1:])
+		name := m[1:]
+		if native {
+			// Dots are not allowed in native parameter names. Replacement
+			// could make names of different parameters equal, e.g. @a.b and
+			// @a__b, such parameters can't be used together.
+			nativeName := strings.Replace(name, ".", "__", -1)
+			if prev, ok := seen[nativeName]; ok {
+				if prev != name && err == nil {
+					err = fmt.Errorf("Named parameters @%s and @%s can't be used in the same query", prev, name)
+				}
+				return "@" + nativeName
+			}
+			seen[nativeName] = name
+			val, ok := p.Get(name)
+			if !ok {
+				err = fmt.Errorf("Named parameter %s was not found", m)
+			}
+			args = append(args, sql.Named(nativeName, val))
+			names = append(names, name)
+			return "@" + nativeName
+		}
+		val, ok := p.Get(name)
 		if !ok {
 			err = fmt.Errorf("Named parameter %s was not found", m)
 		}
 		args = append(args, val)
-		return "?"
+		names = append(names, name)
+		return f.placeholder(len(args))
 	})
 	return
 }
 
+// nativeNamedArgs tells if the driver for the flavor supports sql.NamedArg
+// arguments with @name parameters.
+func (f Flavor) nativeNamedArgs() bool {
+	return f == SQLite
+}
+
 //
 // Params
 //
 
 type namedParams interface {
+	// Get returns a value of a parameter. Names of parameters that belong to
+	// nested structs and maps are dotted paths, e.g. "user.id".
 	Get(name string) (val interface{}, ok bool)
 }
 
 var (
 	_ namedParams = namedParamsMap{}
 	_ namedParams = &namedParamsStruct{}
+	_ namedParams = namedParamsArgs{}
 )
 
 func newNamedParams(val interface{}) (namedParams, error) {
 	switch tval := val.(type) {
 	case map[string]interface{}:
 		return newNamedParamsMap(tval)
+	case []sql.NamedArg:
+		return namedParamsArgs(tval), nil
+	case sql.NamedArg:
+		return namedParamsArgs{tval}, nil
 	default:
 		return newNamedParamsStruct(val)
 	}
@@ -61,13 +106,22 @@ func newNamedParamsMap(m map[string]interface{}) (namedParamsMap, error) {
 }
 
 func (p namedParamsMap) Get(name string) (val interface{}, ok bool) {
-	val, ok = p.m[name]
-	return
+	if val, ok = p.m[name]; ok {
+		return val, true
+	}
+	head, rest := splitParamName(name)
+	if rest == "" {
+		return nil, false
+	}
+	if val, ok = p.m[head]; !ok {
+		return nil, false
+	}
+	return resolveParam(reflect.ValueOf(val), rest)
 }
 
 type namedParamsStruct struct {
 	s   reflect.Value
-	idx map[string]int
+	idx map[string][]int
 }
 
 func newNamedParamsStruct(s interface{}) (*namedParamsStruct, error) {
@@ -80,13 +134,145 @@ func newNamedParamsStruct(s interface{}) (*namedParamsStruct, error) {
 	}
 	return &namedParamsStruct{
 		s:   val,
-		idx: reflect2.TagIndex(val.Type(), tagName),
+		idx: paramFieldIndex(val.Type()),
 	}, nil
 }
 
 func (p *namedParamsStruct) Get(name string) (val interface{}, ok bool) {
 	if i, ok := p.idx[name]; ok {
-		return p.s.Field(i).Interface(), true
+		f, ok := fieldByIndex(p.s, i)
+		if !ok {
+			return nil, false
+		}
+		return f.Interface(), true
+	}
+	head, rest := splitParamName(name)
+	if rest == "" {
+		return nil, false
+	}
+	i, ok := p.idx[head]
+	if !ok {
+		return nil, false
+	}
+	f, ok := fieldByIndex(p.s, i)
+	if !ok {
+		return nil, false
+	}
+	return resolveParam(f, rest)
+}
+
+// namedParamsArgs allows using sql.Named arguments as named parameters.
+type namedParamsArgs []sql.NamedArg
+
+func (p namedParamsArgs) Get(name string) (val interface{}, ok bool) {
+	head, rest := splitParamName(name)
+	for _, arg := range p {
+		switch arg.Name {
+		case name:
+			return arg.Value, true
+		case head:
+			val, ok = resolveParam(reflect.ValueOf(arg.Value), rest)
+		}
+	}
+	return val, ok
+}
+
+// resolveParam resolves a dotted path through nested structs, maps and
+// pointers.
+func resolveParam(val reflect.Value, path string) (interface{}, bool) {
+	for path != "" {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return nil, false
+			}
+			val = val.Elem()
+		}
+		var name string
+		name, path = splitParamName(path)
+		switch val.Kind() {
+		case reflect.Struct:
+			i, ok := paramFieldIndex(val.Type())[name]
+			if !ok {
+				return nil, false
+			}
+			if val, ok = fieldByIndex(val, i); !ok {
+				return nil, false
+			}
+		case reflect.Map:
+			if val.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			val = val.MapIndex(reflect.ValueOf(name).Convert(val.Type().Key()))
+			if !val.IsValid() {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return val.Interface(), true
+}
+
+// paramFieldIndex returns the field index of a struct type from the type
+// metadata cache, see buildFieldIndex.
+func paramFieldIndex(typ reflect.Type) map[string][]int {
+	return getTypeMeta(typ).index
+}
+
+// buildFieldIndex maps tag values of struct fields to field index sequences.
+// Fields of embedded structs and struct pointers without tags are promoted
+// like in Go, fields of outer structs take precedence.
+func buildFieldIndex(typ reflect.Type) map[string][]int {
+	return buildFieldIndexOf(typ, map[reflect.Type]bool{})
+}
+
+// buildFieldIndexOf builds a field index skipping embedded types that are
+// already being indexed, which is possible with embedded pointers.
+func buildFieldIndexOf(typ reflect.Type, visiting map[reflect.Type]bool) map[string][]int {
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	idx := map[string][]int{}
+	var embedded []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _ := reflect2.ParseTag(f.Tag.Get(tagName))
+		switch {
+		case name != "" && name != "-":
+			idx[name] = []int{i}
+		case name == "" && f.Anonymous && embeddedStructType(f.Type) != nil:
+			embedded = append(embedded, i)
+		}
+	}
+	for _, i := range embedded {
+		etyp := embeddedStructType(typ.Field(i).Type)
+		if visiting[etyp] {
+			continue
+		}
+		for name, sub := range buildFieldIndexOf(etyp, visiting) {
+			if _, ok := idx[name]; !ok {
+				idx[name] = append([]int{i}, sub...)
+			}
+		}
+	}
+	return idx
+}
+
+// embeddedStructType returns a struct type of an embedded field that is a
+// struct or a pointer to a struct. It returns nil for other types.
+func embeddedStructType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+func splitParamName(name string) (head, rest string) {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[:i], name[i+1:]
 	}
-	return nil, false
+	return name, ""
 }
@@ -1,6 +1,8 @@
 package dbc
 
 import (
+	"database/sql"
+	"reflect"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -13,7 +15,7 @@ func TestPrepareNamedQuery(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create named params map: %v", err)
 	}
-	q, args, err := prepareNamedQuery(q, p)
+	q, args, names, err := prepareNamedQuery(MySQL, q, p)
 	if err != nil {
 		t.Fatalf("Failed to prepare named statement: %v", err)
 	}
@@ -26,6 +28,25 @@ func TestPrepareNamedQuery(t *testing.T) {
 	if !cmp.Equal(expA, args) {
 		t.Errorf("Returned arguments are different: %s", cmp.Diff(expA, args))
 	}
+	expN := []string{"name", "is_active"}
+	if !cmp.Equal(expN, names) {
+		t.Errorf("Returned argument names are different: %s", cmp.Diff(expN, names))
+	}
+}
+
+func TestPrepareNamedQueryPostgreSQL(t *testing.T) {
+	p, err := newNamedParamsMap(map[string]interface{}{"name": "Bob", "is_active": 1})
+	if err != nil {
+		t.Fatalf("Failed to create named params map: %v", err)
+	}
+	q, _, _, err := prepareNamedQuery(PostgreSQL, `SELECT id FROM tbl WHERE name = @name AND active = @is_active`, p)
+	if err != nil {
+		t.Fatalf("Failed to prepare named statement: %v", err)
+	}
+	const expQ = `SELECT id FROM tbl WHERE name = $1 AND active = $2`
+	if q != expQ {
+		t.Errorf("Expected query to be\n%s\ngot\n%q", expQ, q)
+	}
 }
 
 func TestNamedParamsMap(t *testing.T) {
@@ -114,7 +135,7 @@ func BenchmarkPrepareNamedOne(b *testing.B) {
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		prepareNamedQuery(q, p)
+		prepareNamedQuery(MySQL, q, p)
 	}
 }
 
@@ -131,6 +152,151 @@ func BenchmarkPrepareNamedQuerySix(b *testing.B) {
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		prepareNamedQuery(q, p)
+		prepareNamedQuery(MySQL, q, p)
+	}
+}
+
+func TestNamedParamsNested(t *testing.T) {
+	type Pagination struct {
+		Limit int `db:"limit"`
+	}
+	type filter struct {
+		Since string `db:"since"`
+	}
+	type request struct {
+		Pagination
+		User *struct {
+			ID int `db:"id"`
+		} `db:"user"`
+		Filter filter                 `db:"filter"`
+		Extra  map[string]interface{} `db:"extra"`
+	}
+	req := &request{
+		Pagination: Pagination{Limit: 10},
+		User: &struct {
+			ID int `db:"id"`
+		}{ID: 5},
+		Filter: filter{Since: "2020-01-01"},
+		Extra:  map[string]interface{}{"tags": map[string]string{"a": "b"}},
+	}
+	p, err := newNamedParams(req)
+	if err != nil {
+		t.Fatalf("Failed to create named params: %v", err)
+	}
+	exp := map[string]interface{}{
+		"limit":        10,
+		"user.id":      5,
+		"filter.since": "2020-01-01",
+		"extra.tags.a": "b",
+	}
+	for name, expVal := range exp {
+		if v, ok := p.Get(name); !ok || v != expVal {
+			t.Errorf("Expected %s to be %v, got %v", name, expVal, v)
+		}
+	}
+	for _, name := range []string{"user.name", "filter.since.year", "extra.missing", "pagination"} {
+		if _, ok := p.Get(name); ok {
+			t.Errorf("Parameter %s reportedly found", name)
+		}
+	}
+
+	mp, _ := newNamedParams(map[string]interface{}{"user": req.User})
+	if v, ok := mp.Get("user.id"); !ok || v != 5 {
+		t.Errorf("Expected user.id to be 5, got %v", v)
+	}
+}
+
+func TestPrepareNamedQueryNative(t *testing.T) {
+	p, err := newNamedParams([]sql.NamedArg{sql.Named("user", map[string]int{"id": 1})})
+	if err != nil {
+		t.Fatalf("Failed to create named params: %v", err)
+	}
+	q, args, names, err := prepareNamedQuery(SQLite, `SELECT * FROM tbl WHERE a = @user.id OR b = @user.id`, p)
+	if err != nil {
+		t.Fatalf("Failed to prepare named statement: %v", err)
+	}
+	const expQ = `SELECT * FROM tbl WHERE a = @user__id OR b = @user__id`
+	if q != expQ {
+		t.Errorf("Expected query to be\n%s\ngot\n%q", expQ, q)
+	}
+	// sql.NamedArg has an unexported field that cmp can't compare.
+	expA := []interface{}{sql.Named("user__id", 1)}
+	if !reflect.DeepEqual(expA, args) {
+		t.Errorf("Expected arguments %v, got %v", expA, args)
+	}
+	if !reflect.DeepEqual([]string{"user.id"}, names) {
+		t.Errorf("Unexpected argument names: %v", names)
+	}
+}
+
+func TestPrepareNamedQueryNativeCollision(t *testing.T) {
+	p, err := newNamedParams(map[string]interface{}{
+		"user":     map[string]int{"id": 1},
+		"user__id": 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create named params: %v", err)
+	}
+	_, _, _, err = prepareNamedQuery(SQLite, `SELECT * FROM tbl WHERE a = @user.id OR b = @user__id`, p)
+	if err == nil {
+		t.Error("Expected colliding parameter names to be rejected")
+	}
+	if _, _, _, err = prepareNamedQuery(MySQL, `SELECT * FROM tbl WHERE a = @user.id OR b = @user__id`, p); err != nil {
+		t.Errorf("Expected parameters to be accepted with positional placeholders, got %v", err)
+	}
+}
+
+type namedAudit struct {
+	CreatedBy string `db:"created_by"`
+}
+
+type namedRecord struct {
+	*namedAudit
+	ID int `db:"id"`
+}
+
+type NamedAudit struct {
+	CreatedBy string `db:"created_by"`
+}
+
+type namedRecordExported struct {
+	*NamedAudit
+	ID int `db:"id"`
+}
+
+func TestNamedParamsEmbeddedPointer(t *testing.T) {
+	p, err := newNamedParams(namedRecord{namedAudit: &namedAudit{CreatedBy: "alice"}, ID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create named params: %v", err)
+	}
+	if v, ok := p.Get("created_by"); !ok || v != "alice" {
+		t.Errorf("Expected created_by to be alice, got %v", v)
+	}
+
+	p, err = newNamedParams(namedRecord{ID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create named params: %v", err)
+	}
+	if _, ok := p.Get("created_by"); ok {
+		t.Error("Expected field of nil embedded struct not to be found")
+	}
+	if v, ok := p.Get("id"); !ok || v != 1 {
+		t.Errorf("Expected id to be 1, got %v", v)
+	}
+
+	mp, _ := newNamedParams(map[string]interface{}{"rec": namedRecord{ID: 1}})
+	if _, ok := mp.Get("rec.created_by"); ok {
+		t.Error("Expected nested field of nil embedded struct not to be found")
+	}
+}
+
+func TestBuildFieldIndexRecursive(t *testing.T) {
+	type node struct {
+		*node
+		ID int `db:"id"`
+	}
+	idx := buildFieldIndex(reflect.TypeOf(node{}))
+	if exp := map[string][]int{"id": {1}}; !reflect.DeepEqual(exp, idx) {
+		t.Errorf("Expected field index %v, got %v", exp, idx)
 	}
 }
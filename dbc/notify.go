@@ -0,0 +1,235 @@
+package dbc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/lib/pq"
+	"github.com/localhots/gobelt/log"
+)
+
+// Notification is a message received from a PostgreSQL notification channel.
+type Notification struct {
+	Channel string
+	Payload string
+	// PID is the process ID of the server backend that sent the notification.
+	PID int
+	// Reconnected is set on a notification that is delivered after the
+	// listening connection was reestablished. It carries no payload and
+	// indicates that notifications sent while the connection was down are
+	// lost.
+	Reconnected bool
+	// Dropped is the number of notifications that were not delivered to the
+	// subscriber before this one because its buffer was full. Subscribers
+	// that don't keep up lose notifications instead of stalling delivery to
+	// other subscribers.
+	Dropped int
+}
+
+const (
+	listenerMinReconnectInterval = 10 * time.Millisecond
+	listenerMaxReconnectInterval = time.Minute
+	listenerPingInterval         = 90 * time.Second
+	notificationBufferSize       = 32
+)
+
+func (c *dbWrapper) Listen(ctx context.Context, channel string) (<-chan Notification, error) {
+	if c.flavor != PostgreSQL {
+		return nil, errors.Errorf("Notifications are not supported by %s flavor", c.flavor)
+	}
+
+	c.lmu.Lock()
+	if c.listener == nil {
+		c.listener = newListener(c.dsn)
+	}
+	l := c.listener
+	c.lmu.Unlock()
+
+	return l.subscribe(ctx, channel)
+}
+
+func (c *dbWrapper) Notify(ctx context.Context, channel, payload string) error {
+	if c.flavor != PostgreSQL {
+		return errors.Errorf("Notifications are not supported by %s flavor", c.flavor)
+	}
+	return c.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload).Error()
+}
+
+// listener maintains a dedicated connection that is used to listen to
+// notification channels. The connection is reestablished automatically and
+// all channels that have subscribers are listened to again.
+type listener struct {
+	pql *pq.Listener
+	// smu serializes LISTEN and UNLISTEN commands.
+	smu sync.Mutex
+	// mu guards subscribers and is held while notifications are delivered.
+	// Delivery never blocks.
+	mu   sync.Mutex
+	subs map[string]map[*subscriber]struct{}
+	done chan struct{}
+}
+
+type subscriber struct {
+	ch chan Notification
+	// dropped is the number of notifications dropped since the last
+	// delivered one.
+	dropped int
+}
+
+func newListener(dsn string) *listener {
+	l := &listener{
+		subs: make(map[string]map[*subscriber]struct{}),
+		done: make(chan struct{}),
+	}
+	l.pql = pq.NewListener(dsn, listenerMinReconnectInterval, listenerMaxReconnectInterval, l.handleEvent)
+	go l.run()
+	return l
+}
+
+func (l *listener) subscribe(ctx context.Context, channel string) (<-chan Notification, error) {
+	l.smu.Lock()
+	defer l.smu.Unlock()
+
+	l.mu.Lock()
+	_, ok := l.subs[channel]
+	l.mu.Unlock()
+	if !ok {
+		err := l.pql.Listen(channel)
+		if err != nil && err != pq.ErrChannelAlreadyOpen {
+			return nil, errors.Annotatef(err, "Failed to listen to channel %s", channel)
+		}
+	}
+
+	s := &subscriber{
+		ch: make(chan Notification, notificationBufferSize),
+	}
+	l.mu.Lock()
+	if _, ok := l.subs[channel]; !ok {
+		l.subs[channel] = make(map[*subscriber]struct{})
+	}
+	l.subs[channel][s] = struct{}{}
+	l.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.unsubscribe(channel, s)
+		case <-l.done:
+		}
+	}()
+
+	return s.ch, nil
+}
+
+func (l *listener) unsubscribe(channel string, s *subscriber) {
+	l.smu.Lock()
+	defer l.smu.Unlock()
+
+	l.mu.Lock()
+	subs, ok := l.subs[channel]
+	if !ok {
+		// Listener was closed.
+		l.mu.Unlock()
+		return
+	}
+	delete(subs, s)
+	close(s.ch)
+	empty := len(subs) == 0
+	if empty {
+		delete(l.subs, channel)
+	}
+	l.mu.Unlock()
+
+	if empty {
+		err := l.pql.Unlisten(channel)
+		if err != nil && err != pq.ErrChannelNotOpen {
+			log.Error(context.Background(), "Failed to unlisten from channel", log.F{
+				"channel": channel,
+				"error":   err,
+			})
+		}
+	}
+}
+
+func (l *listener) run() {
+	defer l.closeAll()
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case n, ok := <-l.pql.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// Connection was reestablished.
+				l.broadcastReconnect()
+				continue
+			}
+			l.deliver(n.Channel, Notification{
+				Channel: n.Channel,
+				Payload: n.Extra,
+				PID:     n.BePid,
+			})
+		case <-ping.C:
+			go l.pql.Ping()
+		}
+	}
+}
+
+func (l *listener) deliver(channel string, n Notification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for s := range l.subs[channel] {
+		sn := n
+		sn.Dropped = s.dropped
+		select {
+		case s.ch <- sn:
+			s.dropped = 0
+		default:
+			s.dropped++
+		}
+	}
+}
+
+func (l *listener) broadcastReconnect() {
+	l.mu.Lock()
+	channels := make([]string, 0, len(l.subs))
+	for channel := range l.subs {
+		channels = append(channels, channel)
+	}
+	l.mu.Unlock()
+	for _, channel := range channels {
+		l.deliver(channel, Notification{Channel: channel, Reconnected: true})
+	}
+}
+
+func (l *listener) closeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	close(l.done)
+	for channel, subs := range l.subs {
+		for s := range subs {
+			close(s.ch)
+		}
+		delete(l.subs, channel)
+	}
+}
+
+func (l *listener) handleEvent(ev pq.ListenerEventType, err error) {
+	if err == nil {
+		return
+	}
+	switch ev {
+	case pq.ListenerEventDisconnected:
+		log.Warn(context.Background(), "Notification listener disconnected", log.F{"error": err})
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Error(context.Background(), "Notification listener failed to connect", log.F{"error": err})
+	}
+}
+
+func (l *listener) close() error {
+	return l.pql.Close()
+}
@@ -0,0 +1,88 @@
+package dbc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestListenUnsupported(t *testing.T) {
+	requireConn(t)
+	if flavor == PostgreSQL {
+		t.Skip("Notifications are supported by PostgreSQL")
+	}
+	ctx := context2.TestContext(t)
+	if _, err := conn.Listen(ctx, "sqldb_test"); err == nil {
+		t.Error("Expected Listen to fail")
+	}
+	if err := conn.Notify(ctx, "sqldb_test", "hello"); err == nil {
+		t.Error("Expected Notify to fail")
+	}
+}
+
+func TestListenNotify(t *testing.T) {
+	requireConn(t)
+	if flavor != PostgreSQL {
+		t.Skip("Notifications require PostgreSQL")
+	}
+	ctx, cancel := context.WithTimeout(context2.TestContext(t), 5*time.Second)
+	defer cancel()
+
+	ch, err := conn.Listen(ctx, "sqldb_test")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	if err := conn.Notify(ctx, "sqldb_test", "hello"); err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+
+	select {
+	case n := <-ch:
+		if n.Channel != "sqldb_test" || n.Payload != "hello" {
+			t.Errorf("Unexpected notification: %+v", n)
+		}
+	case <-ctx.Done():
+		t.Fatal("Notification was not received")
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestListenerStuckSubscriber(t *testing.T) {
+	l := &listener{subs: map[string]map[*subscriber]struct{}{}}
+	stuck := &subscriber{ch: make(chan Notification, 1)}
+	other := &subscriber{ch: make(chan Notification, notificationBufferSize)}
+	l.subs["a"] = map[*subscriber]struct{}{stuck: {}}
+	l.subs["b"] = map[*subscriber]struct{}{other: {}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			l.deliver("a", Notification{Channel: "a"})
+		}
+		l.broadcastReconnect()
+		l.deliver("b", Notification{Channel: "b", Payload: "hello"})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Delivery is blocked by a subscriber that doesn't read")
+	}
+
+	if n := <-other.ch; !n.Reconnected {
+		t.Errorf("Expected reconnect notification, got %+v", n)
+	}
+	if n := <-other.ch; n.Payload != "hello" || n.Dropped != 0 {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+	<-stuck.ch
+	l.deliver("a", Notification{Channel: "a"})
+	if n := <-stuck.ch; n.Dropped != 3 {
+		t.Errorf("Expected 3 dropped notifications, got %d", n.Dropped)
+	}
+}
@@ -0,0 +1,182 @@
+package dbc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// PageQuery describes a query that is paginated using keyset pagination.
+type PageQuery struct {
+	// Query is a base query without ORDER BY and LIMIT clauses. It can contain
+	// named parameters.
+	Query string
+	// Args are named parameters for the base query.
+	Args map[string]interface{}
+	// Keys is an ordered set of columns that define the order of rows. A
+	// combination of key values must be unique, so the last key is usually a
+	// primary key. Key columns must be selected by the base query and must not
+	// contain NULL values.
+	Keys []PageKey
+	// Size is the maximum number of rows in a page.
+	Size int
+}
+
+// PageKey describes a column used for pagination.
+type PageKey struct {
+	Column string
+	Desc   bool
+}
+
+const pageCursorParam = "page_cursor_"
+
+// QueryPage executes a paginated query and loads a page of rows that follow
+// the given cursor into dest, which must be a pointer to a slice of structs.
+// Items that dest already contains are replaced. An empty cursor requests the
+// first page. The returned cursor should be used to fetch the next page, it is
+// empty when there are no more rows.
+func (c *caller) QueryPage(ctx context.Context, q PageQuery, cursor string, dest interface{}) (next string, err error) {
+	if len(q.Keys) == 0 {
+		return "", errors.New("Page query requires at least one key column")
+	}
+	if q.Size <= 0 {
+		return "", errors.New("Page size must be positive")
+	}
+	dval := reflect.ValueOf(dest)
+	if dval.Kind() != reflect.Ptr ||
+		dval.Elem().Kind() != reflect.Slice ||
+		dval.Elem().Type().Elem().Kind() != reflect.Struct {
+		return "", errors.New("Destination must be a pointer to a slice of structs")
+	}
+
+	args := make(map[string]interface{}, len(q.Args)+len(q.Keys))
+	for k, v := range q.Args {
+		args[k] = v
+	}
+	var vals []interface{}
+	if cursor != "" {
+		vals, err = decodePageCursor(cursor)
+		if err != nil {
+			return "", err
+		}
+		if len(vals) != len(q.Keys) {
+			return "", errors.New("Page cursor does not match key columns")
+		}
+		for i, v := range vals {
+			args[pageCursorParam+strconv.Itoa(i)] = v
+		}
+	}
+
+	// Load appends to the slice, so items of a previous page are discarded.
+	slice := dval.Elem()
+	slice.SetLen(0)
+	err = c.QueryNamed(ctx, buildPageQuery(q, vals != nil), args).Load(dest)
+	if err != nil {
+		return "", err
+	}
+
+	if slice.Len() <= q.Size {
+		return "", nil
+	}
+	slice.Set(slice.Slice(0, q.Size))
+	return encodePageCursor(slice.Index(q.Size-1), q.Keys)
+}
+
+// buildPageQuery wraps the base query into a subquery so that its own WHERE
+// clause is left intact. One extra row is requested to tell if there is a
+// next page.
+func buildPageQuery(q PageQuery, withCursor bool) string {
+	var buf bytes.Buffer
+	buf.WriteString("SELECT * FROM (")
+	buf.WriteString(q.Query)
+	buf.WriteString(") AS page_base")
+	if withCursor {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(pagePredicate(q.Keys))
+	}
+	buf.WriteString(" ORDER BY ")
+	for i, k := range q.Keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(k.Column)
+		if k.Desc {
+			buf.WriteString(" DESC")
+		} else {
+			buf.WriteString(" ASC")
+		}
+	}
+	buf.WriteString(" LIMIT ")
+	buf.WriteString(strconv.Itoa(q.Size + 1))
+	return buf.String()
+}
+
+// pagePredicate builds a condition that matches rows following the cursor.
+// For keys (a, b) it is: (a > @a) OR (a = @a AND b > @b). Row value
+// comparison is not used because keys can have different directions.
+func pagePredicate(keys []PageKey) string {
+	disj := make([]string, len(keys))
+	for i := range keys {
+		conj := make([]string, i+1)
+		for j := 0; j < i; j++ {
+			conj[j] = keys[j].Column + " = @" + pageCursorParam + strconv.Itoa(j)
+		}
+		op := " > @"
+		if keys[i].Desc {
+			op = " < @"
+		}
+		conj[i] = keys[i].Column + op + pageCursorParam + strconv.Itoa(i)
+		disj[i] = "(" + strings.Join(conj, " AND ") + ")"
+	}
+	return "(" + strings.Join(disj, " OR ") + ")"
+}
+
+func encodePageCursor(row reflect.Value, keys []PageKey) (string, error) {
+	idx := paramFieldIndex(row.Type())
+	vals := make([]interface{}, len(keys))
+	for i, k := range keys {
+		fi, ok := idx[k.Column]
+		if !ok {
+			return "", errors.Errorf("Key column %s is not mapped to a struct field", k.Column)
+		}
+		f, ok := fieldByIndex(row, fi)
+		if !ok {
+			return "", errors.Errorf("Key column %s belongs to a nil embedded struct", k.Column)
+		}
+		vals[i] = f.Interface()
+	}
+	body, err := json.Marshal(vals)
+	if err != nil {
+		return "", errors.Annotate(err, "Failed to encode page cursor")
+	}
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+func decodePageCursor(cursor string) ([]interface{}, error) {
+	body, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Annotate(err, "Invalid page cursor")
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var vals []interface{}
+	if err := dec.Decode(&vals); err != nil {
+		return nil, errors.Annotate(err, "Invalid page cursor")
+	}
+	for i, v := range vals {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				vals[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				vals[i] = fv
+			}
+		}
+	}
+	return vals, nil
+}
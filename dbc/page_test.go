@@ -0,0 +1,83 @@
+package dbc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestBuildPageQuery(t *testing.T) {
+	q := PageQuery{
+		Query: "SELECT id, name FROM tbl WHERE active = @active",
+		Keys:  []PageKey{{Column: "name", Desc: true}, {Column: "id"}},
+		Size:  10,
+	}
+	const expFirst = "SELECT * FROM (SELECT id, name FROM tbl WHERE active = @active) AS page_base " +
+		"ORDER BY name DESC, id ASC LIMIT 11"
+	if out := buildPageQuery(q, false); out != expFirst {
+		t.Errorf("Expected query to be\n%s\ngot\n%s", expFirst, out)
+	}
+	const expNext = "SELECT * FROM (SELECT id, name FROM tbl WHERE active = @active) AS page_base " +
+		"WHERE ((name < @page_cursor_0) OR (name = @page_cursor_0 AND id > @page_cursor_1)) " +
+		"ORDER BY name DESC, id ASC LIMIT 11"
+	if out := buildPageQuery(q, true); out != expNext {
+		t.Errorf("Expected query to be\n%s\ngot\n%s", expNext, out)
+	}
+}
+
+func TestPageCursor(t *testing.T) {
+	type row struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	keys := []PageKey{{Column: "name"}, {Column: "id"}}
+	cur, err := encodePageCursor(reflect.ValueOf(row{ID: 9007199254740993, Name: "Bob"}), keys)
+	if err != nil {
+		t.Fatalf("Failed to encode cursor: %v", err)
+	}
+	vals, err := decodePageCursor(cur)
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	exp := []interface{}{"Bob", int64(9007199254740993)}
+	if !cmp.Equal(exp, vals) {
+		t.Errorf("Cursor values don't match: %s", cmp.Diff(exp, vals))
+	}
+
+	if _, err := decodePageCursor("not a cursor"); err == nil {
+		t.Error("Expected invalid cursor to produce an error")
+	}
+}
+
+func TestQueryPage(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	q := PageQuery{
+		Query: "SELECT id, name FROM sqldb_test",
+		Keys:  []PageKey{{Column: "id"}},
+		Size:  1,
+	}
+
+	var page1 []record
+	cur, err := conn.QueryPage(ctx, q, "", &page1)
+	mustQuery(t, err)
+	if exp := []record{{ID: 1, Name: "Alice"}}; !cmp.Equal(exp, page1) {
+		t.Errorf("First page doesn't match: %s", cmp.Diff(exp, page1))
+	}
+	if cur == "" {
+		t.Fatal("Expected a cursor for the next page")
+	}
+
+	// Destination slice is reused for the next page.
+	page2 := page1
+	cur, err = conn.QueryPage(ctx, q, cur, &page2)
+	mustQuery(t, err)
+	if exp := []record{{ID: 2, Name: "Bob"}}; !cmp.Equal(exp, page2) {
+		t.Errorf("Second page doesn't match: %s", cmp.Diff(exp, page2))
+	}
+	if cur != "" {
+		t.Errorf("Expected no cursor after the last page, got %q", cur)
+	}
+}
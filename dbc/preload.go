@@ -0,0 +1,244 @@
+package dbc
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/localhots/gobelt/reflect2"
+)
+
+// RelationKind defines how parent and related rows are associated.
+type RelationKind int
+
+const (
+	// HasMany relation means that related rows reference parent rows, e.g. a
+	// user has many posts.
+	HasMany RelationKind = iota
+	// BelongsTo relation means that parent rows reference related rows, e.g.
+	// a post belongs to a user.
+	BelongsTo
+)
+
+// Relation describes an association between parent structs and rows of a
+// related table.
+type Relation struct {
+	Kind RelationKind
+	// Table is the name of the related table.
+	Table string
+	// ForeignKey is the referencing column. It is a column of the related
+	// table for has-many relations and a column of the parent table for
+	// belongs-to relations.
+	ForeignKey string
+	// References is the referenced column. A column of a field with pk tag
+	// option is used if it is not set.
+	References string
+	// Field is the name of the parent struct field that related rows are
+	// attached to. It must be a slice of structs for has-many relations and a
+	// struct or a pointer to a struct for belongs-to relations.
+	Field string
+}
+
+type preloadPlan struct {
+	rel        Relation
+	field      int
+	fieldType  reflect.Type
+	childType  reflect.Type
+	localKey   int
+	remoteKey  int
+	remoteCol  string
+	isChildPtr bool
+}
+
+// Preload loads rows related to parents with a single query and attaches them
+// to parent struct fields. Parents must be a slice of structs or pointers to
+// structs, or a pointer to such slice. Key fields of parent and related
+// structs must have the same type. Options are applied to the query of
+// related rows, see Select.
+func Preload(ctx context.Context, db Querier, parents interface{}, rel Relation, opts ...SelectOption) error {
+	pval := reflect.Indirect(reflect.ValueOf(parents))
+	if pval.Kind() != reflect.Slice {
+		panic("Value must be a slice or a pointer to a slice")
+	}
+	ptyp := pval.Type().Elem()
+	if ptyp.Kind() == reflect.Ptr {
+		ptyp = ptyp.Elem()
+	}
+	if ptyp.Kind() != reflect.Struct {
+		panic("Value must be a slice of structs")
+	}
+
+	p, err := newPreloadPlan(ptyp, rel)
+	if err != nil {
+		return err
+	}
+
+	keys := p.keys(pval)
+	if len(keys) == 0 {
+		return nil
+	}
+	names := make([]string, len(keys))
+	params := make(map[string]interface{}, len(keys))
+	for i, key := range keys {
+		names[i] = "@key" + strconv.Itoa(i)
+		params[names[i][1:]] = key
+	}
+	children := reflect.New(reflect.SliceOf(p.childType))
+	where := p.remoteCol + " IN (" + strings.Join(names, ", ") + ")"
+	if err := Select(ctx, db, rel.Table, children.Interface(), where, params, opts...); err != nil {
+		return errors.Annotatef(err, "Failed to preload %s", rel.Field)
+	}
+	p.attach(pval, children.Elem())
+	return nil
+}
+
+func newPreloadPlan(ptyp reflect.Type, rel Relation) (*preloadPlan, error) {
+	f, ok := ptyp.FieldByName(rel.Field)
+	if !ok || len(f.Index) > 1 {
+		return nil, errors.Errorf("Struct %s has no field %s", ptyp, rel.Field)
+	}
+	p := &preloadPlan{rel: rel, field: f.Index[0], fieldType: f.Type}
+
+	ctyp := f.Type
+	switch rel.Kind {
+	case HasMany:
+		if ctyp.Kind() != reflect.Slice {
+			return nil, errors.Errorf("Field %s of a has-many relation must be a slice", rel.Field)
+		}
+		ctyp = ctyp.Elem()
+	case BelongsTo:
+		if ctyp.Kind() == reflect.Ptr {
+			ctyp = ctyp.Elem()
+			p.isChildPtr = true
+		}
+	}
+	if ctyp.Kind() != reflect.Struct {
+		return nil, errors.Errorf("Field %s must hold structs", rel.Field)
+	}
+	p.childType = ctyp
+
+	localType, remoteType := ptyp, ctyp
+	localCol, remoteCol := rel.References, rel.ForeignKey
+	if rel.Kind == BelongsTo {
+		localCol, remoteCol = rel.ForeignKey, rel.References
+	}
+	var err error
+	if p.localKey, err = keyField(localType, localCol); err != nil {
+		return nil, err
+	}
+	if p.remoteKey, err = keyField(remoteType, remoteCol); err != nil {
+		return nil, err
+	}
+	p.remoteCol = remoteCol
+	if p.remoteCol == "" {
+		p.remoteCol, _ = reflect2.ParseTag(remoteType.Field(p.remoteKey).Tag.Get(tagName))
+	}
+	return p, nil
+}
+
+// keyField returns an index of a field mapped to a column, or of a primary
+// key field if column is empty.
+func keyField(typ reflect.Type, col string) (int, error) {
+	fields, err := structFields(typ)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range fields {
+		if (col == "" && f.pk) || (col != "" && f.column == col) {
+			return f.index, nil
+		}
+	}
+	if col == "" {
+		return 0, errors.Errorf("Struct %s has no primary key fields", typ)
+	}
+	return 0, errors.Errorf("Struct %s has no field mapped to column %s", typ, col)
+}
+
+// keys returns unique non-nil values of parent key fields.
+func (p *preloadPlan) keys(parents reflect.Value) []interface{} {
+	var keys []interface{}
+	seen := make(map[interface{}]struct{}, parents.Len())
+	for i := 0; i < parents.Len(); i++ {
+		key, ok := p.parentKey(parents.Index(i))
+		if !ok {
+			continue
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (p *preloadPlan) parentKey(parent reflect.Value) (interface{}, bool) {
+	if parent.Kind() == reflect.Ptr {
+		if parent.IsNil() {
+			return nil, false
+		}
+		parent = parent.Elem()
+	}
+	return fieldKey(parent.Field(p.localKey))
+}
+
+// fieldKey returns a value of a key field. Nil pointers are not keys.
+func fieldKey(val reflect.Value) (interface{}, bool) {
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, false
+		}
+		val = val.Elem()
+	}
+	return val.Interface(), true
+}
+
+// attach assigns related rows to parent struct fields. Has-many fields of
+// parents without related rows are set to empty slices.
+func (p *preloadPlan) attach(parents, children reflect.Value) {
+	groups := make(map[interface{}]reflect.Value, children.Len())
+	for i := 0; i < children.Len(); i++ {
+		child := children.Index(i)
+		key, ok := fieldKey(child.Field(p.remoteKey))
+		if !ok {
+			continue
+		}
+		if p.rel.Kind == BelongsTo {
+			groups[key] = child
+			continue
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = reflect.MakeSlice(p.fieldType, 0, 1)
+		}
+		groups[key] = reflect.Append(group, child)
+	}
+
+	for i := 0; i < parents.Len(); i++ {
+		parent := parents.Index(i)
+		if parent.Kind() == reflect.Ptr {
+			if parent.IsNil() {
+				continue
+			}
+			parent = parent.Elem()
+		}
+		field := parent.Field(p.field)
+		key, ok := p.parentKey(parent)
+		related, found := groups[key]
+		switch {
+		case p.rel.Kind == HasMany && ok && found:
+			field.Set(related)
+		case p.rel.Kind == HasMany:
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		case ok && found && p.isChildPtr:
+			ptr := reflect.New(p.childType)
+			ptr.Elem().Set(related)
+			field.Set(ptr)
+		case ok && found:
+			field.Set(related)
+		default:
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package dbc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type preloadUser struct {
+	ID    int           `db:"id,pk"`
+	Name  string        `db:"name"`
+	Posts []preloadPost `db:"-"`
+}
+
+type preloadPost struct {
+	ID     int          `db:"id,pk"`
+	UserID int          `db:"user_id"`
+	Title  string       `db:"title"`
+	User   *preloadUser `db:"-"`
+}
+
+func TestPreloadQuery(t *testing.T) {
+	db := &recordingQueryer{}
+	users := []preloadUser{{ID: 1}, {ID: 2}, {ID: 1}}
+	err := Preload(context.Background(), db, users, Relation{
+		Kind:       HasMany,
+		Table:      "posts",
+		ForeignKey: "user_id",
+		Field:      "Posts",
+	})
+	if err == nil {
+		t.Fatal("Expected recorded query error")
+	}
+	exp := []string{"SELECT id, user_id, title FROM posts WHERE (user_id IN (@key0, @key1))"}
+	if !cmp.Equal(exp, db.queries) {
+		t.Errorf("Queries don't match: %s", cmp.Diff(exp, db.queries))
+	}
+}
+
+func TestPreloadAttachHasMany(t *testing.T) {
+	users := []*preloadUser{{ID: 1}, {ID: 2}}
+	posts := []preloadPost{
+		{ID: 10, UserID: 1, Title: "a"},
+		{ID: 11, UserID: 1, Title: "b"},
+	}
+	p, err := newPreloadPlan(reflect.TypeOf(preloadUser{}), Relation{
+		Kind:       HasMany,
+		ForeignKey: "user_id",
+		Field:      "Posts",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create preload plan: %v", err)
+	}
+	p.attach(reflect.ValueOf(users), reflect.ValueOf(posts))
+
+	if !cmp.Equal(posts, users[0].Posts) {
+		t.Errorf("Posts don't match: %s", cmp.Diff(posts, users[0].Posts))
+	}
+	if users[1].Posts == nil || len(users[1].Posts) != 0 {
+		t.Errorf("Expected empty posts, got %v", users[1].Posts)
+	}
+}
+
+func TestPreloadAttachBelongsTo(t *testing.T) {
+	posts := []preloadPost{{ID: 10, UserID: 1}, {ID: 11, UserID: 3}}
+	users := []preloadUser{{ID: 1, Name: "Alice"}}
+	p, err := newPreloadPlan(reflect.TypeOf(preloadPost{}), Relation{
+		Kind:       BelongsTo,
+		ForeignKey: "user_id",
+		Field:      "User",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create preload plan: %v", err)
+	}
+	if p.remoteCol != "id" {
+		t.Errorf("Expected referenced column to be id, got %q", p.remoteCol)
+	}
+	p.attach(reflect.ValueOf(posts), reflect.ValueOf(users))
+
+	if posts[0].User == nil || posts[0].User.Name != "Alice" {
+		t.Errorf("Expected user to be attached, got %v", posts[0].User)
+	}
+	if posts[1].User != nil {
+		t.Errorf("Expected no user, got %v", posts[1].User)
+	}
+}
@@ -3,21 +3,24 @@ package dbc
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"time"
 )
 
 type connOrTx interface {
-	executer
-	queryPerformer
+	Executer
+	Querier
 }
 
-type executer interface {
+// Executer executes queries that don't return rows. Conn and Tx implement it.
+type Executer interface {
 	// Exec executes a query and does not expect any result.
 	Exec(ctx context.Context, query string, args ...interface{}) ExecResult
 	ExecNamed(ctx context.Context, query string, arg interface{}) ExecResult
 }
 
-type queryPerformer interface {
+// Querier executes queries that return rows. Conn and Tx implement it.
+type Querier interface {
 	// Query executes a query and returns a result object that can later be used
 	// to retrieve values.
 	Query(ctx context.Context, query string, args ...interface{}) Rows
@@ -30,21 +33,23 @@ type stdConnOrTx interface {
 }
 
 type caller struct {
-	db stdConnOrTx
-	cb *callbacks
+	db      stdConnOrTx
+	cb      *callbacks
+	flavor  Flavor
+	cache   *queryCache
+	tags    *queryTags
+	breaker *breaker
+	// inTx is set on callers that belong to transactions. Transactions don't
+	// read from cache and keep track of tables they write to, so that cache
+	// could be invalidated again on commit.
+	inTx bool
+	// tmu guards touched, a transaction can be used from several goroutines.
+	tmu     sync.Mutex
+	touched []string
 }
 
 func (c *caller) Exec(ctx context.Context, query string, args ...interface{}) ExecResult {
-	c.cb.callBefore(ctx, query)
-	startedAt := time.Now()
-	res, err := c.db.ExecContext(ctx, query, args...)
-	c.cb.callAfter(ctx, query, time.Since(startedAt), err)
-
-	return &execResult{
-		db:  c,
-		err: err,
-		res: res,
-	}
+	return c.exec(ctx, &QueryEvent{Query: query, Args: args})
 }
 
 func (c *caller) ExecNamed(ctx context.Context, query string, arg interface{}) ExecResult {
@@ -52,32 +57,80 @@ func (c *caller) ExecNamed(ctx context.Context, query string, arg interface{}) E
 	if err != nil {
 		return &execResult{err: err}
 	}
-	preparedQuery, args, err := prepareNamedQuery(query, params)
+	preparedQuery, args, names, err := prepareNamedQuery(c.flavor, query, params)
 	if err != nil {
 		return &execResult{err: err}
 	}
-	return c.Exec(ctx, preparedQuery, args...)
+	return c.exec(ctx, &QueryEvent{Query: preparedQuery, Args: args, ArgNames: names})
 }
 
-func (c *caller) Query(ctx context.Context, query string, args ...interface{}) Rows {
-	c.cb.callBefore(ctx, query)
+func (c *caller) exec(ctx context.Context, e *QueryEvent) ExecResult {
+	gen, err := c.breaker.allow()
+	if err != nil {
+		return &execResult{err: err}
+	}
+	c.cb.callBefore(ctx, e)
 	startedAt := time.Now()
-	r, err := c.db.QueryContext(ctx, query, args...)
-	c.cb.callAfter(ctx, query, time.Since(startedAt), err)
-	return &rows{
-		err:  err,
-		rows: r,
+	res, err := c.db.ExecContext(ctx, c.tags.apply(ctx, e.Query), e.Args...)
+	c.breaker.done(ctx, gen, err)
+	e.Took, e.Err = time.Since(startedAt), err
+	c.cb.callAfter(ctx, e)
+	if c.cache != nil {
+		tables := c.cache.invalidate(e.Query)
+		if c.inTx {
+			c.touch(tables)
+		}
+	}
+
+	return &execResult{
+		db:  c,
+		err: err,
+		res: res,
 	}
 }
 
+func (c *caller) Query(ctx context.Context, query string, args ...interface{}) Rows {
+	return c.queryMaybeCached(ctx, &QueryEvent{Query: query, Args: args})
+}
+
 func (c *caller) QueryNamed(ctx context.Context, query string, arg interface{}) Rows {
 	params, err := newNamedParams(arg)
 	if err != nil {
 		return &rows{err: err}
 	}
-	preparedQuery, args, err := prepareNamedQuery(query, params)
+	preparedQuery, args, names, err := prepareNamedQuery(c.flavor, query, params)
+	if err != nil {
+		return &rows{err: err}
+	}
+	return c.queryMaybeCached(ctx, &QueryEvent{Query: preparedQuery, Args: args, ArgNames: names})
+}
+
+func (c *caller) queryMaybeCached(ctx context.Context, e *QueryEvent) Rows {
+	if c.cache != nil && !c.inTx {
+		if ttl, tags, ok := c.cache.policy(e.Query); ok {
+			return c.cachedQuery(ctx, e, ttl, tags)
+		}
+	}
+	return c.query(ctx, e)
+}
+
+func (c *caller) query(ctx context.Context, e *QueryEvent) *rows {
+	gen, err := c.breaker.allow()
 	if err != nil {
 		return &rows{err: err}
 	}
-	return c.Query(ctx, preparedQuery, args...)
+	c.cb.callBefore(ctx, e)
+	startedAt := time.Now()
+	r, err := c.db.QueryContext(ctx, c.tags.apply(ctx, e.Query), e.Args...)
+	c.breaker.done(ctx, gen, err)
+	e.Took, e.Err = time.Since(startedAt), err
+	c.cb.callAfter(ctx, e)
+	return &rows{
+		err:     err,
+		rows:    r,
+		flavor:  c.flavor,
+		ctx:     ctx,
+		breaker: c.breaker,
+		gen:     gen,
+	}
 }
@@ -0,0 +1,115 @@
+package dbc
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Row is a result row that preserves the order of columns. Values are
+// converted the same way as values loaded into maps: NULL values are nil,
+// integers are int64, floating point numbers are float64, decimals and
+// strings are strings.
+type Row struct {
+	// Columns are shared by all rows loaded from the same result and must not
+	// be modified.
+	Columns []string
+	Values  []interface{}
+}
+
+var rowType = reflect.TypeOf(Row{})
+
+// Get returns a value of a column.
+func (r Row) Get(col string) (val interface{}, ok bool) {
+	for i, c := range r.Columns {
+		if c == col {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// rowScanner scans rows into values of types that depend on column types.
+type rowScanner struct {
+	cols []string
+	cats []typeCategory
+	vals []interface{}
+	ptrs []interface{}
+}
+
+func newRowScanner(r *sql.Rows) (*rowScanner, error) {
+	cols, err := r.Columns()
+	if err != nil {
+		return nil, err
+	}
+	colTypes, err := r.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	sc := &rowScanner{
+		cols: cols,
+		cats: make([]typeCategory, len(cols)),
+		vals: make([]interface{}, len(cols)),
+		ptrs: make([]interface{}, len(cols)),
+	}
+	for i, ct := range colTypes {
+		sc.cats[i] = columnTypeCategory(ct.DatabaseTypeName())
+		sc.ptrs[i] = &sc.vals[i]
+	}
+	return sc, nil
+}
+
+// scan scans the current row and returns its values.
+func (sc *rowScanner) scan(r *sql.Rows) ([]interface{}, error) {
+	if err := r.Scan(sc.ptrs...); err != nil {
+		return nil, err
+	}
+	vals := make([]interface{}, len(sc.vals))
+	for i, v := range sc.vals {
+		vals[i] = normalizeValue(v, sc.cats[i])
+	}
+	return vals, nil
+}
+
+// columnTypeCategory returns a category of a database type name as reported
+// by a driver, e.g. "UNSIGNED INT" for MySQL or "VARCHAR(10)" for SQLite.
+func columnTypeCategory(typ string) typeCategory {
+	typ = strings.ToLower(typ)
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = typ[:i]
+	}
+	typ = strings.TrimPrefix(strings.TrimSpace(typ), "unsigned ")
+	return sqlTypeCategory(typ)
+}
+
+// normalizeValue converts a scanned value into a value of a type that matches
+// the column type. Drivers return many values as byte slices, e.g. MySQL
+// returns all values of text protocol queries as bytes.
+func normalizeValue(v interface{}, cat typeCategory) interface{} {
+	switch tv := v.(type) {
+	case []byte:
+		switch cat {
+		case categoryBytes:
+			return tv
+		case categoryInt:
+			if i, err := strconv.ParseInt(string(tv), 10, 64); err == nil {
+				return i
+			}
+		case categoryFloat:
+			if f, err := strconv.ParseFloat(string(tv), 64); err == nil {
+				return f
+			}
+		case categoryBool:
+			if b, err := strconv.ParseBool(string(tv)); err == nil {
+				return b
+			}
+		}
+		return string(tv)
+	case int64:
+		if cat == categoryBool {
+			return tv != 0
+		}
+	}
+	return v
+}
@@ -1,11 +1,11 @@
 package dbc
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"reflect"
-
-	"github.com/localhots/gobelt/reflect2"
 )
 
 // Rows ...
@@ -13,13 +13,24 @@ type Rows interface {
 	Error() error
 	Load(dest interface{}) error
 	Rows() *sql.Rows
+	// WriteCSV streams rows in CSV format with column names in the first row.
+	WriteCSV(w io.Writer) error
+	// WriteJSONLines streams rows as JSON objects separated by newlines.
+	WriteJSONLines(w io.Writer) error
 }
 
 const tagName = "db"
 
 type rows struct {
-	err  error
-	rows *sql.Rows
+	err    error
+	rows   *sql.Rows
+	flavor Flavor
+
+	// ctx, breaker and gen are used to report connection failures that
+	// happen while rows are read.
+	ctx     context.Context
+	breaker *breaker
+	gen     uint64
 }
 
 func (r *rows) Rows() *sql.Rows {
@@ -42,17 +53,25 @@ func (r *rows) Load(dest interface{}) error {
 	}
 	dtyp = dtyp.Elem()
 
-	switch dtyp.Kind() {
-	case reflect.Struct:
+	switch {
+	case isScalarType(dtyp):
+		r.loadValue(dest)
+	case dtyp == rowType:
+		r.loadRow(dest.(*Row))
+	case dtyp.Kind() == reflect.Struct:
 		r.loadStruct(dtyp, dest)
-	case reflect.Map:
-		r.loadMap(dest.(*map[string]interface{}))
-	case reflect.Slice:
-		switch dtyp.Elem().Kind() {
-		case reflect.Struct:
+	case dtyp.Kind() == reflect.Map:
+		r.loadMap(dest)
+	case dtyp.Kind() == reflect.Slice:
+		switch elem := dtyp.Elem(); {
+		case isScalarType(elem):
+			r.loadSlice(dtyp, dest)
+		case elem == rowType:
+			r.loadSliceOfRows(dest.(*[]Row))
+		case elem.Kind() == reflect.Struct:
 			r.loadSliceOfStructs(dtyp, dest)
-		case reflect.Map:
-			r.loadSliceOfMaps(dest.(*[]map[string]interface{}))
+		case elem.Kind() == reflect.Map:
+			r.loadSliceOfMaps(dest)
 		default:
 			r.loadSlice(dtyp, dest)
 		}
@@ -61,10 +80,27 @@ func (r *rows) Load(dest interface{}) error {
 	}
 
 	if r.err == nil && r.rows.Err() != nil {
-		return r.rows.Err()
+		return r.readFailed(r.rows.Err())
 	}
 
-	return r.err
+	return r.readFailed(r.err)
+}
+
+// readFailed reports an error that occurred while rows were read to the
+// circuit breaker and returns it.
+func (r *rows) readFailed(err error) error {
+	if err != nil {
+		r.breaker.fail(r.ctx, r.gen, err)
+	}
+	return err
+}
+
+// isScalarType tells if a value of the type is scanned from a single column.
+// Such types are never loaded as structs, maps or slices, e.g. sql.NullInt64
+// and time.Time are structs and []byte is a slice.
+func isScalarType(typ reflect.Type) bool {
+	return typ == timeType || typ == bytesType ||
+		reflect.PtrTo(typ).Implements(scannerType)
 }
 
 func (r *rows) loadValue(dest interface{}) {
@@ -86,85 +122,119 @@ func (r *rows) loadSlice(typ reflect.Type, dest interface{}) {
 	reflect.ValueOf(dest).Elem().Set(vSlice)
 }
 
-func (r *rows) loadMap(dest *map[string]interface{}) {
+func (r *rows) loadMap(dest interface{}) {
 	if !r.rows.Next() {
 		return
 	}
-
-	cols, err := r.rows.Columns()
+	sc, err := newRowScanner(r.rows)
 	if err != nil {
 		r.err = err
 		return
 	}
-	colTypes, err := r.rows.ColumnTypes()
+	vals, err := sc.scan(r.rows)
 	if err != nil {
 		r.err = err
 		return
 	}
 
-	vals := make([]interface{}, len(cols))
-	for i := range cols {
-		vals[i] = newValue(colTypes[i])
+	switch tdest := dest.(type) {
+	case *map[string]interface{}:
+		if *tdest == nil {
+			*tdest = make(map[string]interface{}, len(sc.cols))
+		}
+		for i, col := range sc.cols {
+			(*tdest)[col] = vals[i]
+		}
+	case *map[string]string:
+		if *tdest == nil {
+			*tdest = make(map[string]string, len(sc.cols))
+		}
+		for i, col := range sc.cols {
+			(*tdest)[col] = formatCSVValue(vals[i])
+		}
+	default:
+		r.err = fmt.Errorf("Unsupported map type: %T", dest)
 	}
-	err = r.rows.Scan(vals...)
+}
+
+func (r *rows) loadSliceOfMaps(dest interface{}) {
+	sc, err := newRowScanner(r.rows)
 	if err != nil {
 		r.err = err
 		return
 	}
 
-	if *dest == nil {
-		*dest = make(map[string]interface{}, len(cols))
-	}
-	for i, col := range cols {
-		switch tval := vals[i].(type) {
-		case *int64:
-			(*dest)[col] = *tval
-		case *string:
-			(*dest)[col] = *tval
-		case *bool:
-			(*dest)[col] = *tval
+	switch tdest := dest.(type) {
+	case *[]map[string]interface{}:
+		if *tdest == nil {
+			*tdest = make([]map[string]interface{}, 0)
+		}
+		for r.rows.Next() {
+			vals, err := sc.scan(r.rows)
+			if err != nil {
+				r.err = err
+				return
+			}
+			row := make(map[string]interface{}, len(sc.cols))
+			for i, col := range sc.cols {
+				row[col] = vals[i]
+			}
+			*tdest = append(*tdest, row)
+		}
+	case *[]map[string]string:
+		if *tdest == nil {
+			*tdest = make([]map[string]string, 0)
+		}
+		for r.rows.Next() {
+			vals, err := sc.scan(r.rows)
+			if err != nil {
+				r.err = err
+				return
+			}
+			row := make(map[string]string, len(sc.cols))
+			for i, col := range sc.cols {
+				row[col] = formatCSVValue(vals[i])
+			}
+			*tdest = append(*tdest, row)
 		}
+	default:
+		r.err = fmt.Errorf("Unsupported slice of maps type: %T", dest)
 	}
 }
 
-func (r *rows) loadSliceOfMaps(dest *[]map[string]interface{}) {
-	cols, err := r.rows.Columns()
+func (r *rows) loadRow(dest *Row) {
+	if !r.rows.Next() {
+		return
+	}
+	sc, err := newRowScanner(r.rows)
 	if err != nil {
 		r.err = err
 		return
 	}
-	colTypes, err := r.rows.ColumnTypes()
+	vals, err := sc.scan(r.rows)
 	if err != nil {
 		r.err = err
 		return
 	}
+	*dest = Row{Columns: sc.cols, Values: vals}
+}
 
+func (r *rows) loadSliceOfRows(dest *[]Row) {
+	sc, err := newRowScanner(r.rows)
+	if err != nil {
+		r.err = err
+		return
+	}
 	if *dest == nil {
-		*dest = make([]map[string]interface{}, 0)
+		*dest = make([]Row, 0)
 	}
 	for r.rows.Next() {
-		vals := make([]interface{}, len(cols))
-		for i := range cols {
-			vals[i] = newValue(colTypes[i])
-		}
-		err = r.rows.Scan(vals...)
+		vals, err := sc.scan(r.rows)
 		if err != nil {
 			r.err = err
 			return
 		}
-
-		row := make(map[string]interface{}, len(cols))
-		for i, col := range cols {
-			switch tval := vals[i].(type) {
-			case *int64:
-				row[col] = *tval
-			case *string:
-				row[col] = *tval
-			case *bool:
-				row[col] = *tval
-			}
-		}
-		*dest = append(*dest, row)
+		*dest = append(*dest, Row{Columns: sc.cols, Values: vals})
 	}
 }
 
@@ -179,27 +249,21 @@ func (r *rows) loadStruct(typ reflect.Type, dest interface{}) {
 		return
 	}
 
-	val := reflect.ValueOf(dest).Elem()
+	// Values are scanned into a copy, so that dest is left intact if scan
+	// fails. Fields that are not mapped to columns keep their values.
+	dval := reflect.ValueOf(dest).Elem()
+	val := reflect.New(typ).Elem()
+	val.Set(dval)
+	copyEmbedded(val)
+	cf := getTypeMeta(typ).columnFields(cols)
 	vals := make([]interface{}, len(cols))
-	tm := reflect2.AssociateColumns(val.Type(), tagName, cols)
-	for i := range cols {
-		if fi, ok := tm[i]; ok {
-			fval := val.Field(fi)
-			vals[i] = reflect.New(fval.Type()).Interface()
-		} else {
-			var dummy interface{}
-			vals[i] = &dummy
-		}
+	if r.err = scanTargets(val, cf, vals); r.err != nil {
+		return
 	}
-
 	if r.err = r.rows.Scan(vals...); r.err != nil {
 		return
 	}
-
-	for i, fi := range tm {
-		fval := val.Field(fi)
-		fval.Set(reflect.ValueOf(vals[i]).Elem())
-	}
+	dval.Set(val)
 }
 
 func (r *rows) loadSliceOfStructs(typ reflect.Type, dest interface{}) {
@@ -210,30 +274,18 @@ func (r *rows) loadSliceOfStructs(typ reflect.Type, dest interface{}) {
 	}
 
 	vSlice := reflect.ValueOf(dest).Elem()
-	tSlice := vSlice.Type()
-	tElem := tSlice.Elem()
-	tm := reflect2.AssociateColumns(tElem, tagName, cols)
+	tElem := typ.Elem()
+	cf := getTypeMeta(tElem).columnFields(cols)
+	vals := make([]interface{}, len(cols))
 
 	for r.rows.Next() {
-		vals := make([]interface{}, len(cols))
 		val := reflect.New(tElem).Elem()
-		for i := range cols {
-			if fi, ok := tm[i]; ok {
-				fval := val.Field(fi)
-				vals[i] = reflect.New(fval.Type()).Interface()
-			} else {
-				vals[i] = nopScanner{}
-			}
+		if r.err = scanTargets(val, cf, vals); r.err != nil {
+			return
 		}
-
 		if r.err = r.rows.Scan(vals...); r.err != nil {
 			return
 		}
-
-		for i, fi := range tm {
-			fval := val.Field(fi)
-			fval.Set(reflect.ValueOf(vals[i]).Elem())
-		}
 		vSlice.Set(reflect.Append(vSlice, val))
 	}
 }
@@ -243,22 +295,6 @@ func (r *rows) withError(err error) Rows {
 	return r
 }
 
-func newValue(typ *sql.ColumnType) interface{} {
-	switch typ.DatabaseTypeName() {
-	case "VARCHAR", "NVARCHAR", "TEXT":
-		var s string
-		return &s
-	case "INT", "BIGINT":
-		var i int64
-		return &i
-	case "BOOL":
-		var b bool
-		return &b
-	default:
-		panic(fmt.Errorf("Unsupported MySQL type: %s", typ.DatabaseTypeName()))
-	}
-}
-
 type nopScanner struct{}
 
 func (s *nopScanner) Scan(interface{}) error { return nil }
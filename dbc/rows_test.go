@@ -1,7 +1,11 @@
 package dbc
 
 import (
+	"context"
+	"database/sql"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/localhots/gobelt/context2"
@@ -65,6 +69,36 @@ func TestLoadStruct(t *testing.T) {
 	}
 }
 
+func TestLoadStructScanError(t *testing.T) {
+	type item struct {
+		Name  string `db:"name"`
+		ID    int64  `db:"id"`
+		Extra string
+	}
+	exp := item{Name: "Alice", ID: 1, Extra: "keep"}
+	out := exp
+	err := replayTestRows(t, &CachedResult{
+		Columns:     []string{"name", "id"},
+		ColumnTypes: []string{"VARCHAR", "BIGINT"},
+		Rows:        [][]interface{}{{"Bob", "not a number"}},
+	}).Load(&out)
+	if err == nil {
+		t.Fatal("Expected scan to fail")
+	}
+	if out != exp {
+		t.Errorf("Expected destination to be left intact, got %+v", out)
+	}
+
+	mustQuery(t, replayTestRows(t, &CachedResult{
+		Columns:     []string{"name", "id"},
+		ColumnTypes: []string{"VARCHAR", "BIGINT"},
+		Rows:        [][]interface{}{{"Bob", int64(2)}},
+	}).Load(&out))
+	if exp := (item{Name: "Bob", ID: 2, Extra: "keep"}); out != exp {
+		t.Errorf("Expected %+v, got %+v", exp, out)
+	}
+}
+
 func TestLoadSliceOfStructs(t *testing.T) {
 	requireConn(t)
 	ctx := context2.TestContext(t)
@@ -78,3 +112,146 @@ func TestLoadSliceOfStructs(t *testing.T) {
 		t.Errorf("Records don't match: %s", cmp.Diff(exp, out))
 	}
 }
+
+func TestLoadSliceOfMapsNulls(t *testing.T) {
+	var out []map[string]interface{}
+	mustQuery(t, exportTestRows(t).Load(&out))
+	exp := []map[string]interface{}{
+		{
+			"id":         int64(1),
+			"name":       `Alice, "A"`,
+			"score":      "1.50",
+			"data":       []byte{0, 1},
+			"created_at": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{"id": int64(2), "name": nil, "score": nil, "data": nil, "created_at": nil},
+	}
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Records don't match: %s", cmp.Diff(exp, out))
+	}
+}
+
+func TestLoadSliceOfStringMaps(t *testing.T) {
+	var out []map[string]string
+	mustQuery(t, exportTestRows(t).Load(&out))
+	exp := []map[string]string{
+		{"id": "1", "name": `Alice, "A"`, "score": "1.50", "data": "AAE=", "created_at": "2020-01-02T03:04:05Z"},
+		{"id": "2", "name": "", "score": "", "data": "", "created_at": ""},
+	}
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Records don't match: %s", cmp.Diff(exp, out))
+	}
+}
+
+func TestLoadRows(t *testing.T) {
+	var out []Row
+	mustQuery(t, exportTestRows(t).Load(&out))
+	if len(out) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(out))
+	}
+	expCols := []string{"id", "name", "score", "data", "created_at"}
+	if !cmp.Equal(expCols, out[1].Columns) {
+		t.Errorf("Columns don't match: %s", cmp.Diff(expCols, out[1].Columns))
+	}
+	if v, ok := out[0].Get("name"); !ok || v != `Alice, "A"` {
+		t.Errorf("Unexpected name value: %v", v)
+	}
+	if v, ok := out[1].Get("score"); !ok || v != nil {
+		t.Errorf("Expected NULL score to be nil, got %v", v)
+	}
+
+	var row Row
+	mustQuery(t, exportTestRows(t).Load(&row))
+	if v, _ := row.Get("id"); v != int64(1) {
+		t.Errorf("Expected first row to be loaded, got id %v", v)
+	}
+}
+
+func TestLoadScanners(t *testing.T) {
+	var id sql.NullInt64
+	mustQuery(t, replayTestRows(t, &CachedResult{
+		Columns:     []string{"id"},
+		ColumnTypes: []string{"BIGINT"},
+		Rows:        [][]interface{}{{int64(1)}},
+	}).Load(&id))
+	if !id.Valid || id.Int64 != 1 {
+		t.Errorf("Expected id to be 1, got %+v", id)
+	}
+
+	var names []sql.NullString
+	mustQuery(t, replayTestRows(t, &CachedResult{
+		Columns:     []string{"name"},
+		ColumnTypes: []string{"VARCHAR"},
+		Rows:        [][]interface{}{{"Alice"}, {nil}},
+	}).Load(&names))
+	expNames := []sql.NullString{{String: "Alice", Valid: true}, {}}
+	if !cmp.Equal(expNames, names) {
+		t.Errorf("Names don't match: %s", cmp.Diff(expNames, names))
+	}
+
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var created time.Time
+	mustQuery(t, replayTestRows(t, &CachedResult{
+		Columns:     []string{"created_at"},
+		ColumnTypes: []string{"DATETIME"},
+		Rows:        [][]interface{}{{ts}},
+	}).Load(&created))
+	if !created.Equal(ts) {
+		t.Errorf("Expected time to be %v, got %v", ts, created)
+	}
+
+	var data []byte
+	mustQuery(t, replayTestRows(t, &CachedResult{
+		Columns:     []string{"data"},
+		ColumnTypes: []string{"BLOB"},
+		Rows:        [][]interface{}{{[]byte{1, 2}}},
+	}).Load(&data))
+	if !cmp.Equal([]byte{1, 2}, data) {
+		t.Errorf("Unexpected bytes: %v", data)
+	}
+}
+
+func replayTestRows(t *testing.T, res *CachedResult) Rows {
+	t.Helper()
+	rr, err := replayDB.QueryContext(context.Background(), "", res)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	return &rows{rows: rr, flavor: MySQL}
+}
+
+func BenchmarkTypeMetaColumnFields(b *testing.B) {
+	typ := reflect.TypeOf(metaUser{})
+	cols := []string{"id", "name", "email"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getTypeMeta(typ).columnFields(cols)
+	}
+}
+
+func BenchmarkLoadSliceOfStructs(b *testing.B) {
+	res := &CachedResult{
+		Columns:     []string{"id", "name", "email"},
+		ColumnTypes: []string{"BIGINT", "VARCHAR", "VARCHAR"},
+		Rows: [][]interface{}{
+			{int64(1), "Alice", "alice@example.com"},
+			{int64(2), "Bob", nil},
+		},
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr, err := replayDB.QueryContext(ctx, "", res)
+		if err != nil {
+			b.Fatalf("Query failed: %v", err)
+		}
+		var users []metaUser
+		if err := (&rows{rows: rr, flavor: MySQL}).Load(&users); err != nil {
+			b.Fatalf("Load failed: %v", err)
+		}
+	}
+}
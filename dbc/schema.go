@@ -0,0 +1,229 @@
+package dbc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Column describes a table column.
+type Column struct {
+	Name string `db:"name"`
+	// Type is a lowercase database type name without modifiers, e.g. "varchar"
+	// for MySQL or "int4" for PostgreSQL.
+	Type string `db:"type"`
+	// FullType is a complete type definition, e.g. "varchar(10)".
+	FullType string `db:"full_type"`
+	Nullable bool   `db:"nullable"`
+	// Default is a column default value expression. It is nil if a column has
+	// no default value.
+	Default    *string `db:"default_value"`
+	PrimaryKey bool    `db:"primary_key"`
+	// AutoIncrement is set on columns that get their values generated by the
+	// database without a default value expression.
+	AutoIncrement bool `db:"auto_increment"`
+}
+
+// Index describes a table index.
+type Index struct {
+	Name string
+	// Columns is a list of indexed columns in index order. Expressions are
+	// omitted.
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+type indexColumn struct {
+	Index   string `db:"index_name"`
+	Unique  bool   `db:"is_unique"`
+	Primary bool   `db:"is_primary"`
+	Column  string `db:"column_name"`
+}
+
+var (
+	schemaTablesQueries = map[Flavor]string{
+		MySQL: `
+			SELECT table_name AS name
+			FROM information_schema.tables
+			WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'
+			ORDER BY table_name`,
+		PostgreSQL: `
+			SELECT c.relname AS name
+			FROM pg_catalog.pg_class c
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p')
+			ORDER BY c.relname`,
+		SQLite: `
+			SELECT name
+			FROM sqlite_master
+			WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+			ORDER BY name`,
+	}
+	schemaColumnsQueries = map[Flavor]string{
+		MySQL: `
+			SELECT
+				column_name AS name,
+				data_type AS type,
+				column_type AS full_type,
+				is_nullable = 'YES' AS nullable,
+				column_default AS default_value,
+				column_key = 'PRI' AS primary_key,
+				extra LIKE '%auto_increment%' AS auto_increment
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ?
+			ORDER BY ordinal_position`,
+		PostgreSQL: `
+			SELECT
+				a.attname AS name,
+				t.typname AS type,
+				pg_catalog.format_type(a.atttypid, a.atttypmod) AS full_type,
+				NOT a.attnotnull AS nullable,
+				pg_catalog.pg_get_expr(d.adbin, d.adrelid) AS default_value,
+				EXISTS (
+					SELECT 1 FROM pg_catalog.pg_index i
+					WHERE i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey)
+				) AS primary_key,
+				a.attidentity <> '' AS auto_increment
+			FROM pg_catalog.pg_attribute a
+			JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			JOIN pg_catalog.pg_type t ON t.oid = a.atttypid
+			LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE n.nspname = current_schema() AND c.relname = $1
+				AND a.attnum > 0 AND NOT a.attisdropped
+			ORDER BY a.attnum`,
+		SQLite: `
+			SELECT
+				name,
+				type AS full_type,
+				"notnull" = 0 AS nullable,
+				dflt_value AS default_value,
+				pk > 0 AS primary_key
+			FROM pragma_table_info(?)
+			ORDER BY cid`,
+	}
+	schemaIndexesQueries = map[Flavor]string{
+		MySQL: `
+			SELECT
+				index_name AS index_name,
+				non_unique = 0 AS is_unique,
+				index_name = 'PRIMARY' AS is_primary,
+				column_name AS column_name
+			FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name IS NOT NULL
+			ORDER BY index_name, seq_in_index`,
+		PostgreSQL: `
+			SELECT
+				ic.relname AS index_name,
+				i.indisunique AS is_unique,
+				i.indisprimary AS is_primary,
+				a.attname AS column_name
+			FROM pg_catalog.pg_index i
+			JOIN pg_catalog.pg_class ic ON ic.oid = i.indexrelid
+			JOIN pg_catalog.pg_class c ON c.oid = i.indrelid
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord) ON true
+			JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum = k.attnum
+			WHERE n.nspname = current_schema() AND c.relname = $1
+			ORDER BY ic.relname, k.ord`,
+		SQLite: `
+			SELECT
+				il.name AS index_name,
+				il."unique" AS is_unique,
+				il.origin = 'pk' AS is_primary,
+				ii.name AS column_name
+			FROM pragma_index_list(?) il
+			JOIN pragma_index_info(il.name) ii
+			WHERE ii.name IS NOT NULL
+			ORDER BY il.name, ii.seqno`,
+	}
+)
+
+// Tables returns names of tables in the current database or schema.
+func (c *caller) Tables(ctx context.Context) ([]string, error) {
+	query, err := c.schemaQuery(schemaTablesQueries)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	if err := c.Query(ctx, query).Load(&tables); err != nil {
+		return nil, errors.Annotate(err, "Failed to load tables")
+	}
+	return tables, nil
+}
+
+// Columns returns table columns in their definition order.
+func (c *caller) Columns(ctx context.Context, table string) ([]Column, error) {
+	query, err := c.schemaQuery(schemaColumnsQueries)
+	if err != nil {
+		return nil, err
+	}
+	var cols []Column
+	if err := c.Query(ctx, query, table).Load(&cols); err != nil {
+		return nil, errors.Annotatef(err, "Failed to load columns of table %s", table)
+	}
+	var pks int
+	for i := range cols {
+		if c.flavor == SQLite {
+			cols[i].Type = sqliteTypeName(cols[i].FullType)
+		}
+		cols[i].Type = strings.ToLower(cols[i].Type)
+		cols[i].FullType = strings.ToLower(cols[i].FullType)
+		if cols[i].PrimaryKey {
+			pks++
+		}
+	}
+	if c.flavor == SQLite && pks == 1 {
+		// A single INTEGER PRIMARY KEY column is an alias for the row ID.
+		for i := range cols {
+			if cols[i].PrimaryKey && cols[i].Type == "integer" {
+				cols[i].AutoIncrement = true
+			}
+		}
+	}
+	return cols, nil
+}
+
+// Indexes returns table indexes ordered by name.
+func (c *caller) Indexes(ctx context.Context, table string) ([]Index, error) {
+	query, err := c.schemaQuery(schemaIndexesQueries)
+	if err != nil {
+		return nil, err
+	}
+	var icols []indexColumn
+	if err := c.Query(ctx, query, table).Load(&icols); err != nil {
+		return nil, errors.Annotatef(err, "Failed to load indexes of table %s", table)
+	}
+
+	var idxs []Index
+	for _, ic := range icols {
+		if len(idxs) == 0 || idxs[len(idxs)-1].Name != ic.Index {
+			idxs = append(idxs, Index{
+				Name:    ic.Index,
+				Unique:  ic.Unique,
+				Primary: ic.Primary,
+			})
+		}
+		last := &idxs[len(idxs)-1]
+		last.Columns = append(last.Columns, ic.Column)
+	}
+	return idxs, nil
+}
+
+func (c *caller) schemaQuery(queries map[Flavor]string) (string, error) {
+	query, ok := queries[c.flavor]
+	if !ok {
+		return "", errors.Errorf("Schema introspection is not supported by %s flavor", c.flavor)
+	}
+	return query, nil
+}
+
+// sqliteTypeName strips modifiers from a declared column type.
+func sqliteTypeName(typ string) string {
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = typ[:i]
+	}
+	return strings.TrimSpace(typ)
+}
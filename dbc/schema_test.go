@@ -0,0 +1,82 @@
+package dbc
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestTables(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	tables, err := conn.Tables(ctx)
+	mustQuery(t, err)
+	for _, tbl := range tables {
+		if tbl == "sqldb_test" {
+			return
+		}
+	}
+	t.Errorf("Table sqldb_test was not found in %v", tables)
+}
+
+func TestColumns(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	cols, err := conn.Columns(ctx, "sqldb_test")
+	mustQuery(t, err)
+	exp := []Column{
+		{Name: "id", Nullable: false, PrimaryKey: true},
+		{Name: "name", Nullable: true, PrimaryKey: false},
+	}
+	opt := cmpopts.IgnoreFields(Column{}, "Type", "FullType", "Default", "AutoIncrement")
+	if !cmp.Equal(exp, cols, opt) {
+		t.Errorf("Columns don't match: %s", cmp.Diff(exp, cols, opt))
+	}
+	if cols[1].Default == nil {
+		t.Error("Expected name column to have a default value")
+	}
+	if cols[1].Type != "varchar" {
+		t.Errorf("Unexpected name column type: %s", cols[1].Type)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	idxs, err := conn.Indexes(ctx, "sqldb_test")
+	mustQuery(t, err)
+	for _, idx := range idxs {
+		if idx.Name == "sqldb_test_name" {
+			exp := Index{Name: "sqldb_test_name", Columns: []string{"name"}}
+			if !cmp.Equal(exp, idx) {
+				t.Errorf("Index doesn't match: %s", cmp.Diff(exp, idx))
+			}
+			return
+		}
+	}
+	t.Errorf("Index sqldb_test_name was not found in %v", idxs)
+}
+
+// MySQL 8 returns information_schema column names in upper case, so every
+// selected column must have a lower case alias to match struct tags.
+func TestMySQLSchemaQueryAliases(t *testing.T) {
+	aliasRegexp := regexp.MustCompile(` AS [a-z_]+$`)
+	queries := []string{
+		schemaTablesQueries[MySQL],
+		schemaColumnsQueries[MySQL],
+		schemaIndexesQueries[MySQL],
+	}
+	for _, q := range queries {
+		list := q[strings.Index(q, "SELECT")+len("SELECT") : strings.Index(q, "FROM")]
+		for _, col := range strings.Split(list, ",") {
+			col = strings.TrimSpace(col)
+			if !aliasRegexp.MatchString(col) {
+				t.Errorf("Column %q has no lower case alias", col)
+			}
+		}
+	}
+}
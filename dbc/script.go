@@ -0,0 +1,164 @@
+package dbc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// ScriptOptions controls script execution.
+type ScriptOptions struct {
+	// Transaction makes all statements execute in a single transaction. Note
+	// that MySQL commits transactions implicitly on most DDL statements.
+	Transaction bool
+	// DryRun makes script only be split into statements which are returned
+	// without being executed.
+	DryRun bool
+}
+
+var (
+	delimiterRegexp   = regexp.MustCompile(`(?i)^DELIMITER[ \t]+(\S+)[^\n]*(?:\n|$)`)
+	dollarQuoteRegexp = regexp.MustCompile(`^\$(?:[a-zA-Z_][a-zA-Z0-9_]*)?\$`)
+)
+
+// ExecScript splits a script into statements and executes them in order. It
+// returns statements that were executed successfully, or all statements in
+// dry run mode.
+func (c *dbWrapper) ExecScript(ctx context.Context, script io.Reader, opts ScriptOptions) ([]string, error) {
+	body, err := ioutil.ReadAll(script)
+	if err != nil {
+		return nil, errors.Annotate(err, "Failed to read script")
+	}
+	stmts, err := splitScript(c.flavor, string(body))
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return stmts, nil
+	}
+	if !opts.Transaction {
+		return execStatements(ctx, c, stmts)
+	}
+
+	var done []string
+	err = c.Begin(ctx, func(tx Tx) error {
+		var err error
+		done, err = execStatements(ctx, tx, stmts)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		// Statements were rolled back.
+		return nil, err
+	}
+	return done, nil
+}
+
+func execStatements(ctx context.Context, db Executer, stmts []string) ([]string, error) {
+	for i, stmt := range stmts {
+		if err := db.Exec(ctx, stmt).Error(); err != nil {
+			return stmts[:i], errors.Annotatef(err, "Statement %d failed", i+1)
+		}
+	}
+	return stmts, nil
+}
+
+// splitScript splits a script into statements. Quoted strings and
+// identifiers, comments and PostgreSQL dollar-quoted strings can contain
+// delimiters. MySQL scripts can change delimiter with a DELIMITER command.
+// Statements that contain only comments are omitted.
+func splitScript(f Flavor, script string) ([]string, error) {
+	var stmts []string
+	var buf strings.Builder
+	var content bool
+	delim := ";"
+	flush := func() {
+		if content {
+			stmts = append(stmts, strings.TrimSpace(buf.String()))
+		}
+		buf.Reset()
+		content = false
+	}
+
+	for i := 0; i < len(script); {
+		rest := script[i:]
+		if f == MySQL && !content {
+			if m := delimiterRegexp.FindStringSubmatch(rest); m != nil {
+				delim = m[1]
+				buf.Reset()
+				i += len(m[0])
+				continue
+			}
+		}
+		if strings.HasPrefix(rest, delim) {
+			flush()
+			i += len(delim)
+			continue
+		}
+
+		n := 1
+		isContent := true
+		switch c := rest[0]; {
+		case c == '\'' || c == '"' || c == '`':
+			n = quotedLength(rest, f == MySQL && c != '`')
+		case strings.HasPrefix(rest, "--") || (f == MySQL && c == '#'):
+			n = strings.IndexByte(rest, '\n') + 1
+			if n == 0 {
+				n = len(rest)
+			}
+			isContent = false
+		case strings.HasPrefix(rest, "/*"):
+			n = strings.Index(rest[2:], "*/") + 4
+			if n == 3 {
+				n = -1
+			}
+			// MySQL executable comments are statement content.
+			isContent = f == MySQL && strings.HasPrefix(rest, "/*!")
+		case f == PostgreSQL && c == '$':
+			if tag := dollarQuoteRegexp.FindString(rest); tag != "" {
+				n = strings.Index(rest[len(tag):], tag)
+				if n >= 0 {
+					n += 2 * len(tag)
+				}
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			isContent = false
+		}
+		if n < 0 {
+			return nil, errors.Errorf("Unterminated quoted string or comment at offset %d", i)
+		}
+		buf.WriteString(rest[:n])
+		content = content || isContent
+		i += n
+	}
+	flush()
+	return stmts, nil
+}
+
+// quotedLength returns the length of a quoted string at the start of s,
+// including quotes. Quotes are escaped by doubling, and by a backslash if
+// backslash escapes are enabled. It returns -1 if the string is unterminated.
+func quotedLength(s string, backslash bool) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case q:
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
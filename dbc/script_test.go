@@ -0,0 +1,89 @@
+package dbc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestSplitScript(t *testing.T) {
+	const script = `
+		-- Comment; with a delimiter
+		INSERT INTO t (a, b) VALUES ('x;y', "it''s; \"quoted\"");
+		/* Block; comment */
+		SELECT ` + "`semi;colon`" + ` FROM t # Hash; comment
+		;
+		/*!40101 SET NAMES utf8 */;
+		DELIMITER //
+		CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; END//
+		DELIMITER ;
+		SELECT 1;
+		-- Trailing comment
+	`
+	exp := []string{
+		"-- Comment; with a delimiter\n\t\tINSERT INTO t (a, b) VALUES ('x;y', \"it''s; \\\"quoted\\\"\")",
+		"/* Block; comment */\n\t\tSELECT `semi;colon` FROM t # Hash; comment",
+		"/*!40101 SET NAMES utf8 */",
+		"CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; END",
+		"SELECT 1",
+	}
+	out, err := splitScript(MySQL, script)
+	if err != nil {
+		t.Fatalf("Failed to split script: %v", err)
+	}
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Statements don't match: %s", cmp.Diff(exp, out))
+	}
+}
+
+func TestSplitScriptPostgreSQL(t *testing.T) {
+	const script = `
+		CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;
+		SELECT 'a\'; SELECT $$;$$
+	`
+	exp := []string{
+		"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+		"SELECT 'a\\'",
+		"SELECT $$;$$",
+	}
+	out, err := splitScript(PostgreSQL, script)
+	if err != nil {
+		t.Fatalf("Failed to split script: %v", err)
+	}
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Statements don't match: %s", cmp.Diff(exp, out))
+	}
+}
+
+func TestSplitScriptUnterminated(t *testing.T) {
+	if _, err := splitScript(MySQL, "SELECT 'foo; SELECT 1"); err == nil {
+		t.Error("Expected unterminated string to produce an error")
+	}
+	if _, err := splitScript(MySQL, "SELECT 1 /* comment"); err == nil {
+		t.Error("Expected unterminated comment to produce an error")
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	const script = `
+		INSERT INTO sqldb_test (id, name) VALUES (10, 'Script');
+		UPDATE sqldb_test SET name = 'Script;2' WHERE id = 10;
+		DELETE FROM sqldb_test WHERE id = 10;
+	`
+
+	stmts, err := conn.ExecScript(ctx, strings.NewReader(script), ScriptOptions{DryRun: true})
+	mustQuery(t, err)
+	if len(stmts) != 3 {
+		t.Errorf("Expected 3 statements, got %d", len(stmts))
+	}
+
+	stmts, err = conn.ExecScript(ctx, strings.NewReader(script), ScriptOptions{Transaction: true})
+	mustQuery(t, err)
+	if len(stmts) != 3 {
+		t.Errorf("Expected 3 statements to be executed, got %d", len(stmts))
+	}
+}
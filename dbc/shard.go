@@ -0,0 +1,249 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// ShardedConn routes queries to one of several connections by a shard key.
+// Shard key is taken from the context, see ContextWithShardKey.
+type ShardedConn struct {
+	shards  []Conn
+	shardFn ShardFunc
+}
+
+// ShardFunc maps a shard key to a shard index in range [0, n).
+type ShardFunc func(key string, n int) int
+
+// ErrNoShardKey is returned when a query is routed using a context that has no
+// shard key.
+var ErrNoShardKey = errors.New("Shard key is not set")
+
+type shardContext byte
+
+const ctxShardKey shardContext = iota
+
+var _ connOrTx = &ShardedConn{}
+
+// NewShardedConn creates a sharded connection. If shard function is nil, keys
+// are distributed with HashShard.
+func NewShardedConn(shards []Conn, fn ShardFunc) (*ShardedConn, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("Sharded connection requires at least one shard")
+	}
+	for i, conn := range shards {
+		if conn == nil {
+			return nil, errors.Errorf("Shard %d connection is nil", i)
+		}
+	}
+	if fn == nil {
+		fn = HashShard
+	}
+	return &ShardedConn{
+		shards:  append([]Conn(nil), shards...),
+		shardFn: fn,
+	}, nil
+}
+
+// HashShard distributes keys evenly using FNV-1a hash. Number of shards must
+// be positive.
+func HashShard(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// ContextWithShardKey returns a new context with given shard key.
+func ContextWithShardKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, ctxShardKey, key)
+}
+
+// ShardKeyFromContext returns a shard key stored in the context.
+func ShardKeyFromContext(ctx context.Context) (key string, ok bool) {
+	key, ok = ctx.Value(ctxShardKey).(string)
+	return key, ok
+}
+
+// Shard returns a connection to the shard that holds given key. It returns an
+// error if the shard function returns an index that is out of range.
+func (s *ShardedConn) Shard(key string) (Conn, error) {
+	i := s.shardFn(key, len(s.shards))
+	if i < 0 || i >= len(s.shards) {
+		return nil, errors.Errorf("Shard function returned index %d for %d shards", i, len(s.shards))
+	}
+	return s.shards[i], nil
+}
+
+// ShardContext returns a connection to the shard that holds the key from the
+// context.
+func (s *ShardedConn) ShardContext(ctx context.Context) (Conn, error) {
+	key, ok := ShardKeyFromContext(ctx)
+	if !ok {
+		return nil, ErrNoShardKey
+	}
+	return s.Shard(key)
+}
+
+// Shards returns all shard connections.
+func (s *ShardedConn) Shards() []Conn {
+	return append([]Conn(nil), s.shards...)
+}
+
+// Exec executes a query on the shard selected by the context.
+func (s *ShardedConn) Exec(ctx context.Context, query string, args ...interface{}) ExecResult {
+	conn, err := s.ShardContext(ctx)
+	if err != nil {
+		return &execResult{err: err}
+	}
+	return conn.Exec(ctx, query, args...)
+}
+
+// ExecNamed executes a query with named parameters on the shard selected by
+// the context.
+func (s *ShardedConn) ExecNamed(ctx context.Context, query string, arg interface{}) ExecResult {
+	conn, err := s.ShardContext(ctx)
+	if err != nil {
+		return &execResult{err: err}
+	}
+	return conn.ExecNamed(ctx, query, arg)
+}
+
+// Query executes a query on the shard selected by the context.
+func (s *ShardedConn) Query(ctx context.Context, query string, args ...interface{}) Rows {
+	conn, err := s.ShardContext(ctx)
+	if err != nil {
+		return &rows{err: err}
+	}
+	return conn.Query(ctx, query, args...)
+}
+
+// QueryNamed executes a query with named parameters on the shard selected by
+// the context.
+func (s *ShardedConn) QueryNamed(ctx context.Context, query string, arg interface{}) Rows {
+	conn, err := s.ShardContext(ctx)
+	if err != nil {
+		return &rows{err: err}
+	}
+	return conn.QueryNamed(ctx, query, arg)
+}
+
+// QueryPage executes a paginated query on the shard selected by the context.
+func (s *ShardedConn) QueryPage(ctx context.Context, q PageQuery, cursor string, dest interface{}) (next string, err error) {
+	conn, err := s.ShardContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	pc, ok := conn.(interface {
+		QueryPage(context.Context, PageQuery, string, interface{}) (string, error)
+	})
+	if !ok {
+		return "", errors.Errorf("Shard connection %T does not support paginated queries", conn)
+	}
+	return pc.QueryPage(ctx, q, cursor, dest)
+}
+
+// Begin executes a transaction on the shard selected by the context.
+func (s *ShardedConn) Begin(ctx context.Context, fn func(Tx) error) error {
+	return s.BeginCustom(ctx, fn, nil)
+}
+
+// BeginCustom executes a transaction with provided options on the shard
+// selected by the context.
+func (s *ShardedConn) BeginCustom(ctx context.Context, fn func(Tx) error, opts *sql.TxOptions) error {
+	conn, err := s.ShardContext(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.BeginCustom(ctx, fn, opts)
+}
+
+// QueryAll executes a query on all shards concurrently and loads combined
+// results into dest, which must be a pointer to a slice. Results are appended
+// in shard order.
+func (s *ShardedConn) QueryAll(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.queryAll(ctx, dest, func(ctx context.Context, conn Conn) Rows {
+		return conn.Query(ctx, query, args...)
+	})
+}
+
+// QueryAllNamed is like QueryAll but it executes a query with named
+// parameters.
+func (s *ShardedConn) QueryAllNamed(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	return s.queryAll(ctx, dest, func(ctx context.Context, conn Conn) Rows {
+		return conn.QueryNamed(ctx, query, arg)
+	})
+}
+
+func (s *ShardedConn) queryAll(ctx context.Context, dest interface{}, query func(context.Context, Conn) Rows) error {
+	dtyp := reflect.TypeOf(dest)
+	if dtyp.Kind() != reflect.Ptr || dtyp.Elem().Kind() != reflect.Slice {
+		panic("Value must be a pointer to a slice")
+	}
+	styp := dtyp.Elem()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]reflect.Value, len(s.shards))
+	var firstErr error
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i, conn := range s.shards {
+		wg.Add(1)
+		go func(i int, conn Conn) {
+			defer wg.Done()
+			res := reflect.New(styp)
+			if err := query(ctx, conn).Load(res.Interface()); err != nil {
+				// Errors caused by cancellation on other shards are ignored.
+				once.Do(func() {
+					firstErr = errors.Annotatef(err, "Query failed on shard %d", i)
+					cancel()
+				})
+				return
+			}
+			results[i] = res.Elem()
+		}(i, conn)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	merged := reflect.MakeSlice(styp, 0, 0)
+	for _, res := range results {
+		merged = reflect.AppendSlice(merged, res)
+	}
+	reflect.ValueOf(dest).Elem().Set(merged)
+	return nil
+}
+
+// Before adds a callback function to all shards.
+func (s *ShardedConn) Before(cb BeforeCallback) {
+	for _, conn := range s.shards {
+		conn.Before(cb)
+	}
+}
+
+// After adds a callback function to all shards.
+func (s *ShardedConn) After(cb AfterCallback) {
+	for _, conn := range s.shards {
+		conn.After(cb)
+	}
+}
+
+// Close closes all shard connections.
+func (s *ShardedConn) Close() error {
+	var firstErr error
+	for i, conn := range s.shards {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Annotatef(err, "Failed to close shard %d", i)
+		}
+	}
+	return firstErr
+}
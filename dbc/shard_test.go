@@ -0,0 +1,105 @@
+package dbc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestHashShard(t *testing.T) {
+	for _, key := range []string{"", "a", "tenant-1", "tenant-2"} {
+		i := HashShard(key, 4)
+		if i < 0 || i >= 4 {
+			t.Errorf("Shard index %d for key %q is out of range", i, key)
+		}
+		if i != HashShard(key, 4) {
+			t.Errorf("Shard index for key %q is not stable", key)
+		}
+	}
+}
+
+func TestNewShardedConn(t *testing.T) {
+	if _, err := NewShardedConn(nil, nil); err == nil {
+		t.Error("Expected sharded connection without shards to be rejected")
+	}
+	if _, err := NewShardedConn([]Conn{testShard(), nil}, nil); err == nil {
+		t.Error("Expected nil shard connection to be rejected")
+	}
+}
+
+func TestShardOutOfRange(t *testing.T) {
+	for _, i := range []int{-1, 2} {
+		sc, err := NewShardedConn([]Conn{testShard(), testShard()}, func(string, int) int { return i })
+		if err != nil {
+			t.Fatalf("Failed to create sharded connection: %v", err)
+		}
+		if _, err := sc.Shard("tenant"); err == nil {
+			t.Errorf("Expected shard index %d to be rejected", i)
+		}
+		ctx := ContextWithShardKey(context.Background(), "tenant")
+		if err := sc.Exec(ctx, "SELECT 1").Error(); err == nil {
+			t.Errorf("Expected query on shard %d to fail", i)
+		}
+	}
+}
+
+func TestShardedConnNoKey(t *testing.T) {
+	sc, err := NewShardedConn([]Conn{testShard()}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create sharded connection: %v", err)
+	}
+	ctx := context.Background()
+	if err := sc.Exec(ctx, "SELECT 1").Error(); err != ErrNoShardKey {
+		t.Errorf("Expected ErrNoShardKey, got %v", err)
+	}
+	if err := sc.Query(ctx, "SELECT 1").Error(); err != ErrNoShardKey {
+		t.Errorf("Expected ErrNoShardKey, got %v", err)
+	}
+}
+
+func TestShardedConnQuery(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	sc, err := NewShardedConn([]Conn{conn, conn}, func(key string, n int) int { return 1 })
+	if err != nil {
+		t.Fatalf("Failed to create sharded connection: %v", err)
+	}
+
+	var id int
+	ctx = ContextWithShardKey(ctx, "tenant")
+	if err := sc.Query(ctx, "SELECT id FROM sqldb_test WHERE name = 'Alice'").Load(&id); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if id != 1 {
+		t.Errorf("Expected id to be 1, got %d", id)
+	}
+}
+
+func TestShardedConnQueryAll(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	sc, err := NewShardedConn([]Conn{conn, conn}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create sharded connection: %v", err)
+	}
+
+	var ids []int
+	if err := sc.QueryAll(ctx, &ids, "SELECT id FROM sqldb_test ORDER BY id"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	exp := []int{1, 2, 1, 2}
+	if !cmp.Equal(exp, ids) {
+		t.Errorf("IDs don't match: %s", cmp.Diff(exp, ids))
+	}
+
+	err = sc.QueryAll(ctx, &ids, "SELECT id FROM sqldb_test_missing")
+	if err == nil {
+		t.Error("Expected query to fail")
+	}
+}
+
+func testShard() Conn {
+	return &dbWrapper{caller: &caller{db: &recordingDB{}, cb: &callbacks{}}}
+}
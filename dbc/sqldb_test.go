@@ -8,6 +8,7 @@ import (
 
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
 	"github.com/localhots/gobelt/log"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
 type record struct {
@@ -15,12 +16,21 @@ type record struct {
 	Name string `db:"name"`
 }
 
-var conn Conn
+var (
+	conn    DB
+	flavor  Flavor
+	testDSN string
+)
+
+const sqliteMemoryDSN = "file:dbc_test?mode=memory&cache=shared"
 
 func TestMain(m *testing.M) {
 	ctx := context.Background()
-	dsn := flag.String("dsn", "", "Database source name")
+	flv := flag.String("flavor", string(SQLite), "Database flavor")
+	dsn := flag.String("dsn", sqliteMemoryDSN, "Database source name")
 	flag.Parse()
+	flavor = Flavor(*flv)
+	testDSN = *dsn
 	if *dsn == "" {
 		log.Warn(ctx, "Database source name is not provided, some tests would be skipped")
 	} else {
@@ -46,7 +56,7 @@ func TestMain(m *testing.M) {
 func connect(ctx context.Context, dsn string) {
 	log.Info(ctx, "Establishing connection to the test database")
 	var err error
-	conn, err = Connect(ctx, MySQL, dsn)
+	conn, err = Connect(ctx, flavor, dsn)
 	if err != nil {
 		log.Fatalf(ctx, "Failed to connect: %v\n", err)
 	}
@@ -55,18 +65,30 @@ func connect(ctx context.Context, dsn string) {
 func seed(ctx context.Context) {
 	log.Info(ctx, "Seeding database")
 	mustExecMain(conn.Exec(ctx, `DROP TABLE IF EXISTS sqldb_test`))
-	mustExecMain(conn.Exec(ctx, `
-		CREATE TABLE sqldb_test (
-			id int(11) UNSIGNED NOT NULL,
-			name VARCHAR(10) DEFAULT '',
-			PRIMARY KEY (id)
-		) ENGINE=InnoDB DEFAULT CHARSET=ascii
-	`))
+	switch flavor {
+	case SQLite:
+		mustExecMain(conn.Exec(ctx, `
+			CREATE TABLE sqldb_test (
+				id INTEGER NOT NULL,
+				name VARCHAR(10) DEFAULT '',
+				PRIMARY KEY (id)
+			)
+		`))
+	default:
+		mustExecMain(conn.Exec(ctx, `
+			CREATE TABLE sqldb_test (
+				id int(11) UNSIGNED NOT NULL,
+				name VARCHAR(10) DEFAULT '',
+				PRIMARY KEY (id)
+			) ENGINE=InnoDB DEFAULT CHARSET=ascii
+		`))
+	}
+	mustExecMain(conn.Exec(ctx, `CREATE INDEX sqldb_test_name ON sqldb_test (name)`))
 	mustExecMain(conn.Exec(ctx, `
 		INSERT INTO sqldb_test (id, name) 
 		VALUES
-			(1, "Alice"),
-			(2, "Bob")
+			(1, 'Alice'),
+			(2, 'Bob')
 	`))
 }
 
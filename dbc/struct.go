@@ -0,0 +1,284 @@
+package dbc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/localhots/gobelt/reflect2"
+)
+
+// Struct-based queries use column names from `db` field tags, fields tagged
+// with "-" are skipped. Tag options follow the column name after a comma:
+//
+//	ID        int        `db:"id,pk"`                 // primary key column
+//	Version   int        `db:"version,version"`       // row version for optimistic locking
+//	DeletedAt *time.Time `db:"deleted_at,softdelete"` // soft delete timestamp
+const (
+	tagOptionPK         = "pk"
+	tagOptionVersion    = "version"
+	tagOptionSoftDelete = "softdelete"
+)
+
+// ErrStaleObject is returned by Update when the row was modified by another
+// session after it had been loaded, as indicated by its version column.
+var ErrStaleObject = errors.New("Row was modified by another session")
+
+type structField struct {
+	column     string
+	index      int
+	pk         bool
+	version    bool
+	softDelete bool
+}
+
+// structFields returns top level tagged fields of a struct type. It returns
+// an error if tag options are not valid for field types.
+func structFields(typ reflect.Type) ([]structField, error) {
+	m := getTypeMeta(typ)
+	return m.fields, m.fieldsErr
+}
+
+func parseStructFields(typ reflect.Type) ([]structField, error) {
+	var fields []structField
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name, opts := reflect2.ParseTag(sf.Tag.Get(tagName))
+		if name == "" || name == "-" {
+			continue
+		}
+		f := structField{column: name, index: i}
+		for _, opt := range opts {
+			switch opt {
+			case tagOptionPK:
+				f.pk = true
+			case tagOptionVersion:
+				f.version = true
+			case tagOptionSoftDelete:
+				if sf.Type != timeType && sf.Type != reflect.PtrTo(timeType) {
+					return nil, errors.Errorf("Soft delete field %s of struct %s must be a time.Time or *time.Time", sf.Name, typ)
+				}
+				f.softDelete = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Update updates a row in a table using values of struct fields. Rows are
+// matched by primary key fields. If a struct has a version field, the row is
+// only updated if its version matches the field value, and the version is
+// incremented, both in the table and in the struct. ErrStaleObject is returned
+// if the row was not updated because of version mismatch.
+func Update(ctx context.Context, db Executer, table string, v interface{}) error {
+	val := structValue(v)
+	typ := val.Type()
+
+	var set, where []string
+	var version *structField
+	fields, err := structFields(typ)
+	if err != nil {
+		return err
+	}
+	for i, f := range fields {
+		cond := f.column + " = @" + f.column
+		switch {
+		case f.pk:
+			where = append(where, cond)
+		case f.softDelete:
+			// Soft delete column is only changed by Delete.
+		case f.version:
+			if version != nil {
+				return errors.Errorf("Struct %s has more than one version field", typ)
+			}
+			version = &fields[i]
+			set = append(set, f.column+" = "+f.column+" + 1")
+			where = append(where, cond)
+		default:
+			set = append(set, cond)
+		}
+	}
+	if !hasPK(fields) {
+		return errors.Errorf("Struct %s has no primary key fields", typ)
+	}
+	if len(set) == 0 {
+		return errors.Errorf("Struct %s has no fields to update", typ)
+	}
+	if version != nil && !isInteger(typ.Field(version.index).Type) {
+		return errors.Errorf("Version field of struct %s must be an integer", typ)
+	}
+
+	query := "UPDATE " + table + " SET " + strings.Join(set, ", ") +
+		" WHERE " + strings.Join(where, " AND ")
+	res := db.ExecNamed(ctx, query, v)
+	if err := res.Error(); err != nil {
+		return err
+	}
+	if version != nil {
+		if res.RowsAffected() == 0 {
+			return ErrStaleObject
+		}
+		incrementInteger(val.Field(version.index))
+	}
+	return nil
+}
+
+// Delete deletes a row from a table. Rows are matched by primary key fields.
+// If a struct has a soft delete field, the row is not removed, instead the
+// field is set to current time, both in the table and in the struct. Rows
+// that are already deleted are not updated, neither are their structs. Soft
+// delete fields must be of time.Time or *time.Time type.
+func Delete(ctx context.Context, db Executer, table string, v interface{}) error {
+	val := structValue(v)
+	typ := val.Type()
+
+	var where []string
+	var softDelete *structField
+	fields, err := structFields(typ)
+	if err != nil {
+		return err
+	}
+	params := make(map[string]interface{}, len(fields))
+	for i, f := range fields {
+		switch {
+		case f.pk:
+			where = append(where, f.column+" = @"+f.column)
+			params[f.column] = val.Field(f.index).Interface()
+		case f.softDelete:
+			softDelete = &fields[i]
+		}
+	}
+	if !hasPK(fields) {
+		return errors.Errorf("Struct %s has no primary key fields", typ)
+	}
+
+	if softDelete == nil {
+		query := "DELETE FROM " + table + " WHERE " + strings.Join(where, " AND ")
+		return db.ExecNamed(ctx, query, params).Error()
+	}
+
+	now := time.Now()
+	col := softDelete.column
+	params[col] = now
+	query := "UPDATE " + table + " SET " + col + " = @" + col +
+		" WHERE " + strings.Join(where, " AND ") + " AND " + col + " IS NULL"
+	res := db.ExecNamed(ctx, query, params)
+	if err := res.Error(); err != nil {
+		return err
+	}
+	if res.RowsAffected() > 0 {
+		setTime(val.Field(softDelete.index), now)
+	}
+	return nil
+}
+
+// SelectOption modifies queries built by Select.
+type SelectOption func(*selectOptions)
+
+type selectOptions struct {
+	withDeleted bool
+}
+
+// WithDeleted makes Select return soft deleted rows.
+func WithDeleted() SelectOption {
+	return func(o *selectOptions) {
+		o.withDeleted = true
+	}
+}
+
+// Select loads rows from a table into dest, which must be a pointer to a
+// struct or to a slice of structs. Columns are selected using struct field
+// tags. Where clause is optional and may use named parameters from arg. Soft
+// deleted rows are excluded unless WithDeleted option is used.
+func Select(ctx context.Context, db Querier, table string, dest interface{}, where string, arg interface{}, opts ...SelectOption) error {
+	var o selectOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	typ := reflect.TypeOf(dest)
+	if typ.Kind() != reflect.Ptr {
+		panic("Value must be a pointer")
+	}
+	typ = typ.Elem()
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		panic("Value must be a pointer to a struct or a slice of structs")
+	}
+
+	fields, err := structFields(typ)
+	if err != nil {
+		return err
+	}
+	cols := make([]string, len(fields))
+	var conds []string
+	if where != "" {
+		conds = append(conds, "("+where+")")
+	}
+	for i, f := range fields {
+		cols[i] = f.column
+		if f.softDelete && !o.withDeleted {
+			conds = append(conds, f.column+" IS NULL")
+		}
+	}
+
+	query := "SELECT " + strings.Join(cols, ", ") + " FROM " + table
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	if arg == nil {
+		arg = map[string]interface{}{}
+	}
+	return db.QueryNamed(ctx, query, arg).Load(dest)
+}
+
+// structValue returns a value of a struct pointed to by v.
+func structValue(v interface{}) reflect.Value {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		panic("Value must be a pointer to a struct")
+	}
+	return val.Elem()
+}
+
+func hasPK(fields []structField) bool {
+	for _, f := range fields {
+		if f.pk {
+			return true
+		}
+	}
+	return false
+}
+
+func isInteger(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func incrementInteger(val reflect.Value) {
+	switch val.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val.SetUint(val.Uint() + 1)
+	default:
+		val.SetInt(val.Int() + 1)
+	}
+}
+
+// setTime sets a time.Time or *time.Time value.
+func setTime(val reflect.Value, t time.Time) {
+	if val.Type() == timeType {
+		val.Set(reflect.ValueOf(t))
+	} else {
+		val.Set(reflect.ValueOf(&t))
+	}
+}
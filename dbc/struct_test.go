@@ -0,0 +1,155 @@
+package dbc
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type recordingExecer struct {
+	queries  []string
+	affected int64
+}
+
+func (e *recordingExecer) Exec(ctx context.Context, query string, args ...interface{}) ExecResult {
+	e.queries = append(e.queries, query)
+	return &execResult{db: e, res: driver.RowsAffected(e.affected)}
+}
+
+func (e *recordingExecer) ExecNamed(ctx context.Context, query string, arg interface{}) ExecResult {
+	return e.Exec(ctx, query)
+}
+
+var errRecorded = errors.New("Query recorded")
+
+type recordingQueryer struct {
+	queries []string
+}
+
+func (q *recordingQueryer) Query(ctx context.Context, query string, args ...interface{}) Rows {
+	q.queries = append(q.queries, query)
+	return &rows{err: errRecorded}
+}
+
+func (q *recordingQueryer) QueryNamed(ctx context.Context, query string, arg interface{}) Rows {
+	return q.Query(ctx, query)
+}
+
+func (q *recordingQueryer) QueryPage(ctx context.Context, pq PageQuery, cursor string, dest interface{}) (string, error) {
+	return "", errRecorded
+}
+
+type versionedRecord struct {
+	ID      int    `db:"id,pk"`
+	Name    string `db:"name"`
+	Version uint   `db:"version,version"`
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	db := &recordingExecer{affected: 1}
+	rec := &versionedRecord{ID: 1, Name: "Alice", Version: 3}
+	if err := Update(ctx, db, "records", rec); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	const exp = "UPDATE records SET name = @name, version = version + 1 WHERE id = @id AND version = @version"
+	if len(db.queries) != 1 || db.queries[0] != exp {
+		t.Errorf("Expected query to be\n%s\ngot\n%v", exp, db.queries)
+	}
+	if rec.Version != 4 {
+		t.Errorf("Expected version to be incremented to 4, got %d", rec.Version)
+	}
+
+	db.affected = 0
+	if err := Update(ctx, db, "records", rec); err != ErrStaleObject {
+		t.Errorf("Expected ErrStaleObject, got %v", err)
+	}
+	if rec.Version != 4 {
+		t.Errorf("Expected version to stay 4, got %d", rec.Version)
+	}
+}
+
+func TestUpdateNoPK(t *testing.T) {
+	rec := &struct {
+		Name string `db:"name"`
+	}{}
+	if err := Update(context.Background(), &recordingExecer{}, "records", rec); err == nil {
+		t.Error("Expected update without primary key to fail")
+	}
+}
+
+type softDeletedRecord struct {
+	ID        int        `db:"id,pk"`
+	Name      string     `db:"name"`
+	DeletedAt *time.Time `db:"deleted_at,softdelete"`
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	db := &recordingExecer{affected: 1}
+	if err := Delete(ctx, db, "records", &versionedRecord{ID: 1}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	rec := &softDeletedRecord{ID: 1}
+	if err := Delete(ctx, db, "records", rec); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	exp := []string{
+		"DELETE FROM records WHERE id = @id",
+		"UPDATE records SET deleted_at = @deleted_at WHERE id = @id AND deleted_at IS NULL",
+	}
+	if !cmp.Equal(exp, db.queries) {
+		t.Errorf("Queries don't match: %s", cmp.Diff(exp, db.queries))
+	}
+	if rec.DeletedAt == nil {
+		t.Error("Expected deletion time to be set")
+	}
+}
+
+func TestDeleteAlreadyDeleted(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := &softDeletedRecord{ID: 1, DeletedAt: &deletedAt}
+	if err := Delete(ctx, &recordingExecer{}, "records", rec); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if rec.DeletedAt != &deletedAt {
+		t.Errorf("Expected deletion time to be left unchanged, got %v", rec.DeletedAt)
+	}
+}
+
+func TestSoftDeleteFieldType(t *testing.T) {
+	rec := &struct {
+		ID        int    `db:"id,pk"`
+		DeletedAt string `db:"deleted_at,softdelete"`
+	}{ID: 1}
+	db := &recordingExecer{affected: 1}
+	if err := Delete(context.Background(), db, "records", rec); err == nil {
+		t.Error("Expected soft delete field of unsupported type to be rejected")
+	}
+	if len(db.queries) != 0 {
+		t.Errorf("Expected no queries to be made, got %v", db.queries)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	ctx := context.Background()
+	db := &recordingQueryer{}
+	var recs []softDeletedRecord
+	var rec softDeletedRecord
+	Select(ctx, db, "records", &recs, "", nil)
+	Select(ctx, db, "records", &rec, "id = @id OR name = @name", rec)
+	Select(ctx, db, "records", &recs, "", nil, WithDeleted())
+	exp := []string{
+		"SELECT id, name, deleted_at FROM records WHERE deleted_at IS NULL",
+		"SELECT id, name, deleted_at FROM records WHERE (id = @id OR name = @name) AND deleted_at IS NULL",
+		"SELECT id, name, deleted_at FROM records",
+	}
+	if !cmp.Equal(exp, db.queries) {
+		t.Errorf("Queries don't match: %s", cmp.Diff(exp, db.queries))
+	}
+}
@@ -0,0 +1,113 @@
+package dbc
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/localhots/gobelt/log"
+)
+
+// QueryTagsConfig configures query tagging. Tags are added to queries as a
+// comment in sqlcommenter format: /* route='%2Fusers',service='api' */
+type QueryTagsConfig struct {
+	// Static tags are added to every query, e.g. service name.
+	Static map[string]string
+	// LogFields is a list of log context fields that are added to queries
+	// as tags. See log.ContextWithFields.
+	LogFields []string
+}
+
+type queryTags struct {
+	static    map[string]string
+	logFields []string
+}
+
+type tagsContext byte
+
+const ctxQueryTags tagsContext = iota
+
+// ContextWithQueryTags returns a new context with given query tags added. Tags
+// from the context are added to queries if query tagging is enabled.
+func ContextWithQueryTags(ctx context.Context, tags map[string]string) context.Context {
+	ctxt, _ := ctx.Value(ctxQueryTags).(map[string]string)
+	merged := make(map[string]string, len(ctxt)+len(tags))
+	for k, v := range ctxt {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, ctxQueryTags, merged)
+}
+
+// WithQueryTags enables query tagging. Tags are prepended to every query as a
+// comment.
+func WithQueryTags(cfg QueryTagsConfig) Option {
+	return func(c *dbWrapper) {
+		c.tags = &queryTags{
+			static:    cfg.Static,
+			logFields: cfg.LogFields,
+		}
+	}
+}
+
+// apply prepends a comment with tags to a query.
+func (t *queryTags) apply(ctx context.Context, query string) string {
+	if cm := t.comment(ctx); cm != "" {
+		return cm + " " + query
+	}
+	return query
+}
+
+// comment returns a comment with tags or an empty string if there are no
+// tags. Context tags override log fields which override static tags.
+func (t *queryTags) comment(ctx context.Context) string {
+	if t == nil {
+		return ""
+	}
+	tags := make(map[string]string, len(t.static)+len(t.logFields))
+	for k, v := range t.static {
+		tags[k] = v
+	}
+	if len(t.logFields) > 0 {
+		f := log.ContextFields(ctx)
+		for _, k := range t.logFields {
+			if v, ok := f[k]; ok {
+				tags[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	ctxt, _ := ctx.Value(ctxQueryTags).(map[string]string)
+	for k, v := range ctxt {
+		tags[k] = v
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return formatQueryTags(tags)
+}
+
+// formatQueryTags formats tags according to sqlcommenter specification. Keys
+// are sorted, keys and values are URL encoded and values are quoted.
+func formatQueryTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = sqlcommenterEscape(k) + "='" + sqlcommenterEscape(tags[k]) + "'"
+	}
+	return "/* " + strings.Join(pairs, ",") + " */"
+}
+
+// sqlcommenterEscape URL encodes a string. Spaces are encoded as %20. Quotes
+// and comment terminators can't appear in the result.
+func sqlcommenterEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
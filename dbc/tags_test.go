@@ -0,0 +1,38 @@
+package dbc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/localhots/gobelt/log"
+)
+
+func TestQueryTagsApply(t *testing.T) {
+	tags := &queryTags{
+		static:    map[string]string{"service": "api", "route": "none"},
+		logFields: []string{"route", "trace_id"},
+	}
+	ctx := log.ContextWithFields(context.Background(), log.F{
+		"route":    "/users/{id}",
+		"trace_id": 123,
+		"user_id":  1,
+	})
+	ctx = ContextWithQueryTags(ctx, map[string]string{"action": "it's */ done"})
+
+	const exp = "/* action='it%27s%20%2A%2F%20done',route='%2Fusers%2F%7Bid%7D'," +
+		"service='api',trace_id='123' */ SELECT 1"
+	if out := tags.apply(ctx, "SELECT 1"); out != exp {
+		t.Errorf("Expected query to be\n%s\ngot\n%s", exp, out)
+	}
+}
+
+func TestQueryTagsApplyEmpty(t *testing.T) {
+	var disabled *queryTags
+	if out := disabled.apply(context.Background(), "SELECT 1"); out != "SELECT 1" {
+		t.Errorf("Expected disabled tagging to keep query intact, got %q", out)
+	}
+	tags := &queryTags{logFields: []string{"route"}}
+	if out := tags.apply(context.Background(), "SELECT 1"); out != "SELECT 1" {
+		t.Errorf("Expected query without tags to be intact, got %q", out)
+	}
+}
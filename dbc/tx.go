@@ -0,0 +1,103 @@
+package dbc
+
+import (
+	"context"
+)
+
+type txContext byte
+
+const ctxTx txContext = iota
+
+// ContextWithTx returns a new context that carries a transaction. Queries
+// made on the connection the transaction belongs to with such context are
+// made inside of the transaction. This includes schema inspection, scripts,
+// CopyFrom and Notify. Named locks (WithLock and TryWithLock) and Listen are
+// the exceptions: they are bound to a session and always use a dedicated
+// connection, so locks are held independently of the transaction.
+func ContextWithTx(ctx context.Context, tx Tx) context.Context {
+	return context.WithValue(ctx, ctxTx, tx)
+}
+
+// TxFromContext returns a transaction stored in the context.
+func TxFromContext(ctx context.Context) (tx Tx, ok bool) {
+	tx, ok = ctx.Value(ctxTx).(Tx)
+	return tx, ok
+}
+
+// BeginContext executes a transaction. The function is called with a context
+// that carries the transaction, see ContextWithTx. Transaction is committed if
+// the function returns no error and rolled back otherwise. If the context
+// already carries a transaction of this connection, the function joins it.
+func (c *dbWrapper) BeginContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c.ambientTx(ctx) != nil {
+		return fn(ctx)
+	}
+	return c.Begin(ctx, func(tx Tx) error {
+		if err := fn(ContextWithTx(ctx, tx)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// ambientTx returns a transaction of this connection stored in the context.
+func (c *dbWrapper) ambientTx(ctx context.Context) *txWrapper {
+	tx, _ := TxFromContext(ctx)
+	if jtx, ok := tx.(joinedTx); ok {
+		tx = jtx.Tx
+	}
+	if wtx, ok := tx.(*txWrapper); ok && wtx.conn == c {
+		return wtx
+	}
+	return nil
+}
+
+// callerFor returns a caller of the ambient transaction or of the connection.
+func (c *dbWrapper) callerFor(ctx context.Context) *caller {
+	if tx := c.ambientTx(ctx); tx != nil {
+		return tx.caller
+	}
+	return c.caller
+}
+
+func (c *dbWrapper) Exec(ctx context.Context, query string, args ...interface{}) ExecResult {
+	return c.callerFor(ctx).Exec(ctx, query, args...)
+}
+
+func (c *dbWrapper) ExecNamed(ctx context.Context, query string, arg interface{}) ExecResult {
+	return c.callerFor(ctx).ExecNamed(ctx, query, arg)
+}
+
+func (c *dbWrapper) Query(ctx context.Context, query string, args ...interface{}) Rows {
+	return c.callerFor(ctx).Query(ctx, query, args...)
+}
+
+func (c *dbWrapper) QueryNamed(ctx context.Context, query string, arg interface{}) Rows {
+	return c.callerFor(ctx).QueryNamed(ctx, query, arg)
+}
+
+func (c *dbWrapper) QueryPage(ctx context.Context, q PageQuery, cursor string, dest interface{}) (string, error) {
+	return c.callerFor(ctx).QueryPage(ctx, q, cursor, dest)
+}
+
+func (c *dbWrapper) Tables(ctx context.Context) ([]string, error) {
+	return c.callerFor(ctx).Tables(ctx)
+}
+
+func (c *dbWrapper) Columns(ctx context.Context, table string) ([]Column, error) {
+	return c.callerFor(ctx).Columns(ctx, table)
+}
+
+func (c *dbWrapper) Indexes(ctx context.Context, table string) ([]Index, error) {
+	return c.callerFor(ctx).Indexes(ctx, table)
+}
+
+// joinedTx is passed to functions that begin a transaction inside of an
+// ambient transaction. Commit and rollback are left to the owner of the
+// ambient transaction, which rolls back when an error is returned.
+type joinedTx struct {
+	Tx
+}
+
+func (joinedTx) Commit() error   { return nil }
+func (joinedTx) Rollback() error { return nil }
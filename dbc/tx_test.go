@@ -0,0 +1,138 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/localhots/gobelt/context2"
+)
+
+type recordingDB struct {
+	queries []string
+}
+
+func (db *recordingDB) ExecContext(_ context.Context, query string, _ ...interface{}) (sql.Result, error) {
+	db.queries = append(db.queries, query)
+	return driver.RowsAffected(0), nil
+}
+
+func (db *recordingDB) QueryContext(_ context.Context, query string, _ ...interface{}) (*sql.Rows, error) {
+	db.queries = append(db.queries, query)
+	return nil, errors.New("Not supported")
+}
+
+func TestAmbientTx(t *testing.T) {
+	connDB, txDB, otherDB := &recordingDB{}, &recordingDB{}, &recordingDB{}
+	c := &dbWrapper{caller: &caller{db: connDB, cb: &callbacks{}}}
+	other := &dbWrapper{caller: &caller{db: otherDB, cb: &callbacks{}}}
+	tx := &txWrapper{conn: c, caller: &caller{db: txDB, cb: &callbacks{}, inTx: true}}
+
+	ctx := ContextWithTx(context.Background(), tx)
+	c.Exec(ctx, "UPDATE a SET b = 1")
+	other.Exec(ctx, "UPDATE c SET d = 1")
+	c.Exec(context.Background(), "UPDATE e SET f = 1")
+
+	if len(txDB.queries) != 1 || txDB.queries[0] != "UPDATE a SET b = 1" {
+		t.Errorf("Expected query to join ambient transaction, got %v", txDB.queries)
+	}
+	if len(otherDB.queries) != 1 {
+		t.Errorf("Expected transaction of another connection to be ignored, got %v", otherDB.queries)
+	}
+	if len(connDB.queries) != 1 {
+		t.Errorf("Expected query without transaction to use connection, got %v", connDB.queries)
+	}
+
+	err := c.Begin(ctx, func(jtx Tx) error {
+		if _, ok := jtx.(joinedTx); !ok {
+			t.Errorf("Expected nested transaction to join ambient one, got %T", jtx)
+		}
+		return jtx.Commit()
+	})
+	if err != nil {
+		t.Errorf("Nested transaction failed: %v", err)
+	}
+}
+
+func TestBeginContext(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+	errAbort := errors.New("Abort")
+	err := conn.BeginContext(ctx, func(ctx context.Context) error {
+		if _, ok := TxFromContext(ctx); !ok {
+			t.Error("Expected context to carry transaction")
+		}
+		mustExec(t, conn.Exec(ctx, "UPDATE sqldb_test SET name = 'Eve' WHERE id = 1"))
+		var name string
+		mustQuery(t, conn.Query(ctx, "SELECT name FROM sqldb_test WHERE id = 1").Load(&name))
+		if name != "Eve" {
+			t.Errorf("Expected to read uncommitted name, got %q", name)
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Expected transaction to be aborted, got %v", err)
+	}
+
+	var name string
+	mustQuery(t, conn.Query(ctx, "SELECT name FROM sqldb_test WHERE id = 1").Load(&name))
+	if name != "Alice" {
+		t.Errorf("Expected transaction to be rolled back, got %q", name)
+	}
+}
+
+func TestBeginContextHelpers(t *testing.T) {
+	requireConn(t)
+	if flavor == MySQL {
+		t.Skip("DDL statements are not transactional in MySQL")
+	}
+	ctx := context2.TestContext(t)
+	errAbort := errors.New("Abort")
+	err := conn.BeginContext(ctx, func(ctx context.Context) error {
+		_, err := conn.ExecScript(ctx, strings.NewReader(`
+			CREATE TABLE sqldb_test_tx (id INTEGER PRIMARY KEY);
+			INSERT INTO sqldb_test_tx (id) VALUES (1);
+		`), ScriptOptions{Transaction: true})
+		if err != nil {
+			t.Fatalf("Script failed: %v", err)
+		}
+		tables, err := conn.Tables(ctx)
+		if err != nil {
+			t.Fatalf("Failed to load tables: %v", err)
+		}
+		if !containsString(tables, "sqldb_test_tx") {
+			t.Errorf("Expected to see uncommitted table, got %v", tables)
+		}
+		cols, err := conn.Columns(ctx, "sqldb_test_tx")
+		if err != nil {
+			t.Fatalf("Failed to load columns: %v", err)
+		}
+		if len(cols) != 1 {
+			t.Errorf("Expected to see columns of uncommitted table, got %v", cols)
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Expected transaction to be aborted, got %v", err)
+	}
+
+	tables, err := conn.Tables(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load tables: %v", err)
+	}
+	if containsString(tables, "sqldb_test_tx") {
+		t.Errorf("Expected table creation to be rolled back, got %v", tables)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package dbc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// SchemaError is returned by Validate when a struct does not match a table.
+type SchemaError struct {
+	Table      string
+	Mismatches []SchemaMismatch
+}
+
+// SchemaMismatch describes a single difference between a struct and a table.
+type SchemaMismatch struct {
+	Column string
+	// Field is the name of a struct field, it is empty for columns that are
+	// not mapped to a field.
+	Field   string
+	Problem string
+}
+
+func (e *SchemaError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Struct does not match table %s:", e.Table)
+	for _, m := range e.Mismatches {
+		buf.WriteString("\n\t")
+		buf.WriteString(m.Column)
+		if m.Field != "" {
+			buf.WriteString(" (")
+			buf.WriteString(m.Field)
+			buf.WriteString(")")
+		}
+		buf.WriteString(": ")
+		buf.WriteString(m.Problem)
+	}
+	return buf.String()
+}
+
+// ColumnLister returns descriptions of table columns. DB implements it.
+type ColumnLister interface {
+	Columns(ctx context.Context, table string) ([]Column, error)
+}
+
+// Validate compares a struct with db tags to a table schema. It reports
+// columns that are missing from the table, columns that can't be omitted from
+// inserts but are not mapped to fields, and fields that can't hold column
+// values. Prototype is a struct value or a pointer to one. A *SchemaError is
+// returned if there are any mismatches.
+func Validate(ctx context.Context, conn ColumnLister, table string, prototype interface{}) error {
+	typ := reflect.TypeOf(prototype)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return errors.Errorf("Prototype must be a struct, got %T", prototype)
+	}
+	cols, err := conn.Columns(ctx, table)
+	if err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return errors.Errorf("Table %s does not exist", table)
+	}
+	if mm := compareSchema(typ, cols); len(mm) > 0 {
+		return &SchemaError{Table: table, Mismatches: mm}
+	}
+	return nil
+}
+
+func compareSchema(typ reflect.Type, cols []Column) []SchemaMismatch {
+	var mm []SchemaMismatch
+	idx := getTypeMeta(typ).index
+	colIdx := make(map[string]Column, len(cols))
+	for _, col := range cols {
+		colIdx[col.Name] = col
+		if _, ok := idx[col.Name]; ok {
+			continue
+		}
+		if !col.Nullable && col.Default == nil && !col.AutoIncrement {
+			mm = append(mm, SchemaMismatch{
+				Column:  col.Name,
+				Problem: "column is not nullable, has no default value and is not mapped to a field",
+			})
+		}
+	}
+
+	// Fields are checked in declaration order, fields of embedded structs
+	// are checked in place of the embedded struct.
+	names := make([]string, 0, len(idx))
+	for name := range idx {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return lessIndex(idx[names[i]], idx[names[j]])
+	})
+	for _, name := range names {
+		f := typ.FieldByIndex(idx[name])
+		col, ok := colIdx[name]
+		if !ok {
+			mm = append(mm, SchemaMismatch{
+				Column:  name,
+				Field:   f.Name,
+				Problem: "column does not exist",
+			})
+			continue
+		}
+		gocat, nullable := goTypeCategory(f.Type)
+		if col.Nullable && !nullable {
+			mm = append(mm, SchemaMismatch{
+				Column:  name,
+				Field:   f.Name,
+				Problem: fmt.Sprintf("column is nullable but field type %s is not", f.Type),
+			})
+		}
+		if !typesCompatible(gocat, sqlTypeCategory(col.Type)) {
+			mm = append(mm, SchemaMismatch{
+				Column:  name,
+				Field:   f.Name,
+				Problem: fmt.Sprintf("field type %s can't hold column type %s", f.Type, col.FullType),
+			})
+		}
+	}
+	return mm
+}
+
+// lessIndex compares field index sequences in declaration order.
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+type typeCategory byte
+
+const (
+	categoryAny typeCategory = iota
+	categoryInt
+	categoryFloat
+	categoryDecimal
+	categoryString
+	categoryBool
+	categoryTime
+	categoryBytes
+)
+
+var sqlTypeCategories = map[string]typeCategory{
+	// Integers
+	"tinyint":   categoryInt,
+	"smallint":  categoryInt,
+	"mediumint": categoryInt,
+	"int":       categoryInt,
+	"integer":   categoryInt,
+	"bigint":    categoryInt,
+	"int2":      categoryInt,
+	"int4":      categoryInt,
+	"int8":      categoryInt,
+	"year":      categoryInt,
+	// Floating point numbers
+	"float":  categoryFloat,
+	"double": categoryFloat,
+	"real":   categoryFloat,
+	"float4": categoryFloat,
+	"float8": categoryFloat,
+	// Decimals
+	"decimal": categoryDecimal,
+	"numeric": categoryDecimal,
+	// Strings
+	"char":       categoryString,
+	"varchar":    categoryString,
+	"bpchar":     categoryString,
+	"tinytext":   categoryString,
+	"text":       categoryString,
+	"mediumtext": categoryString,
+	"longtext":   categoryString,
+	"enum":       categoryString,
+	"set":        categoryString,
+	"json":       categoryString,
+	"jsonb":      categoryString,
+	"uuid":       categoryString,
+	"citext":     categoryString,
+	"clob":       categoryString,
+	// Booleans
+	"bool":    categoryBool,
+	"boolean": categoryBool,
+	// Time
+	"date":        categoryTime,
+	"datetime":    categoryTime,
+	"timestamp":   categoryTime,
+	"timestamptz": categoryTime,
+	// Binary
+	"binary":     categoryBytes,
+	"varbinary":  categoryBytes,
+	"tinyblob":   categoryBytes,
+	"blob":       categoryBytes,
+	"mediumblob": categoryBytes,
+	"longblob":   categoryBytes,
+	"bytea":      categoryBytes,
+}
+
+// sqlTypeCategory returns a category of a database type. Unknown types belong
+// to categoryAny and are not checked.
+func sqlTypeCategory(typ string) typeCategory {
+	return sqlTypeCategories[typ]
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+	nullTypeCategories = map[reflect.Type]typeCategory{
+		reflect.TypeOf(sql.NullString{}):  categoryString,
+		reflect.TypeOf(sql.NullInt64{}):   categoryInt,
+		reflect.TypeOf(sql.NullInt32{}):   categoryInt,
+		reflect.TypeOf(sql.NullInt16{}):   categoryInt,
+		reflect.TypeOf(sql.NullByte{}):    categoryInt,
+		reflect.TypeOf(sql.NullFloat64{}): categoryFloat,
+		reflect.TypeOf(sql.NullBool{}):    categoryBool,
+		reflect.TypeOf(sql.NullTime{}):    categoryTime,
+	}
+)
+
+// goTypeCategory returns a category of a field type and tells if it can hold
+// NULL values.
+func goTypeCategory(typ reflect.Type) (cat typeCategory, nullable bool) {
+	if typ.Kind() == reflect.Ptr {
+		cat, _ = goTypeCategory(typ.Elem())
+		return cat, true
+	}
+	if cat, ok := nullTypeCategories[typ]; ok {
+		return cat, true
+	}
+	switch {
+	case typ == timeType:
+		return categoryTime, false
+	case typ == bytesType:
+		return categoryBytes, true
+	case reflect.PtrTo(typ).Implements(scannerType):
+		return categoryAny, true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return categoryInt, false
+	case reflect.Float32, reflect.Float64:
+		return categoryFloat, false
+	case reflect.String:
+		return categoryString, false
+	case reflect.Bool:
+		return categoryBool, false
+	case reflect.Interface:
+		return categoryAny, true
+	default:
+		return categoryAny, false
+	}
+}
+
+func typesCompatible(gocat, sqlcat typeCategory) bool {
+	if gocat == categoryAny || sqlcat == categoryAny || gocat == sqlcat {
+		return true
+	}
+	switch gocat {
+	case categoryFloat:
+		return sqlcat == categoryInt || sqlcat == categoryDecimal
+	case categoryString:
+		return sqlcat == categoryDecimal || sqlcat == categoryTime || sqlcat == categoryBytes
+	case categoryBool:
+		// MySQL booleans are tinyint columns.
+		return sqlcat == categoryInt
+	case categoryBytes:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,93 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/localhots/gobelt/context2"
+)
+
+func TestCompareSchema(t *testing.T) {
+	def := "0"
+	cols := []Column{
+		{Name: "id", Type: "int", FullType: "int(11)", PrimaryKey: true, AutoIncrement: true},
+		{Name: "name", Type: "varchar", FullType: "varchar(10)", Nullable: true},
+		{Name: "age", Type: "int", FullType: "int(11)", Nullable: true},
+		{Name: "score", Type: "int", FullType: "int(11)", Default: &def},
+		{Name: "email", Type: "varchar", FullType: "varchar(100)"},
+	}
+	type base struct {
+		ID uint `db:"id"`
+	}
+	type user struct {
+		base
+		Name   string         `db:"name"`
+		Age    sql.NullInt64  `db:"age"`
+		Score  string         `db:"score"`
+		Gone   *string        `db:"gone"`
+		Ignore map[string]int // Not mapped
+		Skip   []string       `db:"-"`
+	}
+	exp := []SchemaMismatch{
+		{Column: "email", Problem: "column is not nullable, has no default value and is not mapped to a field"},
+		{Column: "name", Field: "Name", Problem: "column is nullable but field type string is not"},
+		{Column: "score", Field: "Score", Problem: "field type string can't hold column type int(11)"},
+		{Column: "gone", Field: "Gone", Problem: "column does not exist"},
+	}
+	out := compareSchema(reflect.TypeOf(user{}), cols)
+	if !cmp.Equal(exp, out) {
+		t.Errorf("Mismatches don't match: %s", cmp.Diff(exp, out))
+	}
+}
+
+type staticColumns []Column
+
+func (s staticColumns) Columns(context.Context, string) ([]Column, error) {
+	return s, nil
+}
+
+func TestValidateColumnLister(t *testing.T) {
+	cols := staticColumns{
+		{Name: "id", Type: "int", FullType: "int(11)", PrimaryKey: true, AutoIncrement: true},
+		{Name: "name", Type: "varchar", FullType: "varchar(10)"},
+	}
+	type user struct {
+		ID   uint   `db:"id"`
+		Name string `db:"name"`
+	}
+	if err := Validate(context.Background(), cols, "users", user{}); err != nil {
+		t.Errorf("Expected struct to be valid, got: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	requireConn(t)
+	ctx := context2.TestContext(t)
+
+	type valid struct {
+		ID   uint    `db:"id"`
+		Name *string `db:"name"`
+	}
+	if err := Validate(ctx, conn, "sqldb_test", valid{}); err != nil {
+		t.Errorf("Expected struct to be valid, got: %v", err)
+	}
+
+	err := Validate(ctx, conn, "sqldb_test", &record{})
+	serr, ok := err.(*SchemaError)
+	if !ok {
+		t.Fatalf("Expected schema error, got: %v", err)
+	}
+	exp := []SchemaMismatch{
+		{Column: "name", Field: "Name", Problem: "column is nullable but field type string is not"},
+	}
+	if !cmp.Equal(exp, serr.Mismatches) {
+		t.Errorf("Mismatches don't match: %s", cmp.Diff(exp, serr.Mismatches))
+	}
+
+	if err := Validate(ctx, conn, "sqldb_missing", valid{}); err == nil {
+		t.Error("Expected validation of a missing table to fail")
+	}
+}
@@ -18,6 +18,16 @@ func ContextWithFields(ctx context.Context, f F) context.Context {
 	return context.WithValue(ctx, ctxFields, ctxf)
 }
 
+// ContextFields returns a copy of fields stored in the context.
+func ContextFields(ctx context.Context) F {
+	ctxf := contextFields(ctx)
+	f := make(F, len(ctxf))
+	for k, v := range ctxf {
+		f[k] = v
+	}
+	return f
+}
+
 func contextFields(ctx context.Context) F {
 	f, ok := ctx.Value(ctxFields).(F)
 	if ok {
@@ -112,12 +112,18 @@ func mergeFields(ctx context.Context, fields []F) F {
 	if len(ctxf) == 0 && len(fields) == 0 {
 		return nil
 	}
+	// Context fields are copied so that message fields don't leak into the
+	// context.
+	f := make(F, len(ctxf))
+	for k, v := range ctxf {
+		f[k] = v
+	}
 	for i := 0; i < len(fields); i++ {
 		for k, v := range fields[i] {
-			ctxf[k] = v
+			f[k] = v
 		}
 	}
-	return ctxf
+	return f
 }
 
 func withFields(f F) *logrus.Entry {
@@ -1,19 +1,30 @@
 package reflect2
 
-import "reflect"
+import (
+	"reflect"
+	"strings"
+)
 
-// TagIndex returns a map that associates tag values with field indices.
+// TagIndex returns a map that associates tag values with field indices. Tag
+// options that follow the name after a comma are ignored.
 func TagIndex(typ reflect.Type, tag string) map[string]int {
 	tagIndex := map[string]int{}
 	for i := 0; i < typ.NumField(); i++ {
-		tag := typ.Field(i).Tag.Get(tag)
-		if tag != "" {
-			tagIndex[tag] = i
+		name, _ := ParseTag(typ.Field(i).Tag.Get(tag))
+		if name != "" {
+			tagIndex[name] = i
 		}
 	}
 	return tagIndex
 }
 
+// ParseTag splits a tag value into a name and a list of options, e.g.
+// `db:"id,pk"` is split into "id" and ["pk"].
+func ParseTag(tag string) (name string, opts []string) {
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
 // AssociateColumns returns a map that associates column indices with fields.
 func AssociateColumns(typ reflect.Type, tag string, cols []string) map[int]int {
 	tagIndex := TagIndex(typ, tag)